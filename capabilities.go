@@ -0,0 +1,152 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// capabilitiesSchemas are ProxySQL admin schemas which tables are listed by detectCapabilities.
+var capabilitiesSchemas = []string{"main", "stats", "monitor"}
+
+// capabilities describes what the connected ProxySQL supports.
+type capabilities struct {
+	// version is ProxySQL version (major, minor, patch), or nil if it can't be read.
+	version []int
+	// schemas contains schemas which tables were listed; insufficient permissions prevent listing of main and monitor.
+	schemas map[string]bool
+	// tables contains names of existing tables in schema.table form.
+	tables map[string]bool
+
+	detectedAt time.Time
+}
+
+// detectCapabilities reads ProxySQL version and lists tables of capabilitiesSchemas.
+// Version and tables which can't be read due to insufficient permissions are left unknown.
+func detectCapabilities(ctx context.Context, db *sql.DB) (*capabilities, error) {
+	caps := &capabilities{
+		schemas:    make(map[string]bool),
+		tables:     make(map[string]bool),
+		detectedAt: time.Now(),
+	}
+
+	var versionS string
+	err := db.QueryRowContext(ctx, proxySQLVersionQuery).Scan(&versionS)
+	switch {
+	case err == nil:
+		if caps.version, err = parseProxySQLVersion(versionS); err != nil {
+			logger.Debug("Failed to parse ProxySQL version", "version", versionS, "error", err)
+		}
+	case errors.Is(err, sql.ErrNoRows) || isPermissionError(err):
+		logger.Debug("Failed to read ProxySQL version", "error", err)
+	default:
+		return nil, err
+	}
+
+	for _, schema := range capabilitiesSchemas {
+		tables, err := queryTables(ctx, db, schema)
+		if err != nil {
+			if isPermissionError(err) {
+				logger.Debug("Failed to list ProxySQL tables", "schema", schema, "error", err)
+				continue
+			}
+			return nil, err
+		}
+		caps.schemas[schema] = true
+		for _, table := range tables {
+			caps.tables[schema+"."+table] = true
+		}
+	}
+	return caps, nil
+}
+
+// queryTables returns names of tables in ProxySQL admin schema.
+func queryTables(ctx context.Context, db *sql.DB, schema string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SHOW TABLES FROM "+schema)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var tables []string
+	for rows.Next() {
+		var table string
+		if err = rows.Scan(&table); err != nil {
+			return nil, err
+		}
+		tables = append(tables, strings.ToLower(table))
+	}
+	return tables, rows.Err()
+}
+
+var proxySQLVersionRE = regexp.MustCompile(`^(\d+)\.(\d+)\.(\d+)`)
+
+// parseProxySQLVersion parses version like "2.5.5-10-g195bd70" into major, minor and patch numbers.
+func parseProxySQLVersion(s string) ([]int, error) {
+	m := proxySQLVersionRE.FindStringSubmatch(s)
+	if m == nil {
+		return nil, fmt.Errorf("invalid version %q", s)
+	}
+	version := make([]int, 3)
+	for i := range version {
+		version[i], _ = strconv.Atoi(m[i+1])
+	}
+	return version, nil
+}
+
+// compareVersions returns -1, 0 or +1 depending on whether a is older, the same or newer than b.
+func compareVersions(a, b []int) int {
+	for i := range a {
+		switch {
+		case a[i] < b[i]:
+			return -1
+		case a[i] > b[i]:
+			return 1
+		}
+	}
+	return 0
+}
+
+// supports returns true if collector can run against ProxySQL: its version is not older than collector's
+// minimum version and all collector's tables exist. Unknown version and tables of unlisted schemas are assumed
+// to be supported, as well as everything for nil capabilities.
+func (caps *capabilities) supports(c Collector) bool {
+	if caps == nil {
+		return true
+	}
+
+	if minVersion := c.MinVersion(); minVersion != "" && caps.version != nil {
+		// checked by registerCollectors
+		v, _ := parseProxySQLVersion(minVersion)
+		if compareVersions(caps.version, v) < 0 {
+			return false
+		}
+	}
+
+	for _, table := range c.Tables() {
+		schema, _, _ := strings.Cut(table, ".")
+		if caps.schemas[schema] && !caps.tables[table] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestDetectCapabilities(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(proxySQLVersionQuery)).WillReturnRows(sqlmock.NewRows([]string{"variable_value"}).
+		AddRow("2.5.5-10-g195bd70"))
+	mock.ExpectQuery("SHOW TABLES FROM main").WillReturnError(&mysql.MySQLError{Number: 1045, Message: "access denied"})
+	mock.ExpectQuery("SHOW TABLES FROM stats").WillReturnRows(sqlmock.NewRows([]string{"tables"}).
+		AddRow("stats_mysql_global").AddRow("stats_mysql_connection_pool"))
+	mock.ExpectQuery("SHOW TABLES FROM monitor").WillReturnRows(sqlmock.NewRows([]string{"tables"}).
+		AddRow("mysql_server_ping_log"))
+
+	caps, err := detectCapabilities(context.Background(), db)
+	require.NoError(t, err)
+	assert.Equal(t, []int{2, 5, 5}, caps.version)
+	assert.Equal(t, map[string]bool{"stats": true, "monitor": true}, caps.schemas)
+	assert.Equal(t, map[string]bool{
+		"stats.stats_mysql_global":          true,
+		"stats.stats_mysql_connection_pool": true,
+		"monitor.mysql_server_ping_log":     true,
+	}, caps.tables)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	mock.ExpectQuery(sanitizeQuery(proxySQLVersionQuery)).WillReturnError(errors.New("connection refused"))
+	_, err = detectCapabilities(context.Background(), db)
+	assert.Error(t, err)
+}
+
+func TestParseProxySQLVersion(t *testing.T) {
+	v, err := parseProxySQLVersion("1.4.16-percona-1.1")
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 4, 16}, v)
+
+	_, err = parseProxySQLVersion("unknown")
+	assert.Error(t, err)
+
+	assert.Equal(t, -1, compareVersions([]int{1, 4, 16}, []int{2, 0, 0}))
+	assert.Equal(t, 0, compareVersions([]int{2, 0, 0}, []int{2, 0, 0}))
+	assert.Equal(t, 1, compareVersions([]int{2, 0, 1}, []int{2, 0, 0}))
+}
+
+func TestCapabilitiesSupports(t *testing.T) {
+	caps := &capabilities{
+		version: []int{1, 4, 16},
+		schemas: map[string]bool{"stats": true},
+		tables:  map[string]bool{"stats.stats_mysql_global": true},
+	}
+
+	for _, tc := range []struct {
+		c        *collector
+		expected bool
+	}{
+		{&collector{tables: []string{"stats.stats_mysql_global"}}, true},
+		{&collector{tables: []string{"stats.stats_mysql_errors"}}, false},
+		{&collector{tables: []string{"stats.stats_mysql_global"}, minVersion: "2.0.0"}, false},
+		{&collector{tables: []string{"stats.stats_mysql_global"}, minVersion: "1.4.0"}, true},
+		// main schema is not listed
+		{&collector{tables: []string{"main.runtime_mysql_servers"}}, true},
+	} {
+		assert.Equal(t, tc.expected, caps.supports(tc.c), "%v", tc.c)
+	}
+
+	caps.version = nil
+	assert.True(t, caps.supports(&collector{minVersion: "2.0.0"}))
+	assert.True(t, (*capabilities)(nil).supports(&collector{tables: []string{"stats.stats_mysql_errors"}}))
+}
+
+func TestCapabilitiesSupportsTopology(t *testing.T) {
+	// ProxySQL without Galera and AWS Aurora support
+	caps := &capabilities{
+		schemas: map[string]bool{"main": true, "monitor": true},
+		tables: map[string]bool{
+			"main.runtime_mysql_group_replication_hostgroups": true,
+			"monitor.mysql_server_group_replication_log":      true,
+		},
+	}
+
+	supported := make(map[string]bool)
+	for _, c := range collectors {
+		switch c.Name() {
+		case "group_replication", "galera", "aws_aurora":
+			supported[c.Name()] = caps.supports(c)
+		}
+	}
+	assert.Equal(t, map[string]bool{"group_replication": true, "galera": false, "aws_aurora": false}, supported)
+}
+
+func TestSupportedScrapers(t *testing.T) {
+	caps := &capabilities{
+		schemas: map[string]bool{"stats": true},
+		tables:  map[string]bool{"stats.stats_mysql_global": true},
+	}
+	status := &collector{name: "mysql_status", tables: []string{"stats.stats_mysql_global"}}
+	errs := &collector{name: "stats_mysql_errors", tables: []string{"stats.stats_mysql_errors"}}
+
+	ch := make(chan prometheus.Metric, 2)
+	scrapers := supportedScrapers(ch, caps, []Collector{status, errs})
+	close(ch)
+	assert.Equal(t, []Collector{status}, scrapers)
+
+	var metrics []metricResult
+	for m := range ch {
+		metrics = append(metrics, *readMetric(m))
+	}
+	assert.Equal(t, []metricResult{
+		{"proxysql_exporter_collector_supported", prometheus.Labels{"collector": "collect.mysql_status"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_supported", prometheus.Labels{"collector": "collect.stats_mysql_errors"}, 0, dto.MetricType_GAUGE},
+	}, metrics)
+}
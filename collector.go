@@ -0,0 +1,656 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"flag"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Collector collects metrics from one or several related ProxySQL tables.
+// Every registered collector gets collect.<name> flag, so adding a new one requires only registering it in collectors.
+type Collector interface {
+	// Name is used in collect.<name> flag, configuration file and collector label value of exporter metrics.
+	Name() string
+	// Help describes what is collected; it is used in flag usage and documentation.
+	Help() string
+	// DefaultEnabled returns true if the collector is enabled by default.
+	DefaultEnabled() bool
+	// MinVersion returns the minimum ProxySQL version (e.g. "2.0.0") which has collected tables, or empty string.
+	MinVersion() string
+	// Tables returns tables (in schema.table form, e.g. "stats.stats_mysql_global") the collector requires.
+	// The collector is skipped if any of them does not exist.
+	Tables() []string
+	// Scrape collects metrics. Queries must be canceled when ctx is done.
+	Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error
+}
+
+// configurableCollector is a Collector with options.
+// Every option gets collect.<name>.<option> flag and collector_options.<name>.<option> configuration file key,
+// so collectors own their options instead of main and ExporterOptions.
+type configurableCollector interface {
+	Collector
+	// collectorOptions returns options of the collector.
+	collectorOptions() []collectorOption
+	// configure returns a copy of the collector configured with option values, or error if they are invalid.
+	configure(values optionValues) (Collector, error)
+}
+
+// collectorOption describes an option of configurableCollector.
+type collectorOption struct {
+	name     string
+	help     string
+	defValue string
+	isBool   bool
+	// docDefault, if not empty, describes too long default value in README.md.
+	docDefault string
+}
+
+// optionValues contains collector option values by option name.
+type optionValues map[string]string
+
+// invalid returns error for invalid option value.
+func (v optionValues) invalid(name string) error {
+	return fmt.Errorf("not a valid %s option value: %q", name, v[name])
+}
+
+// positiveInt returns value of integer option which must be positive.
+func (v optionValues) positiveInt(name string) (int, error) {
+	i, err := strconv.Atoi(v[name])
+	if err != nil || i <= 0 {
+		return 0, v.invalid(name)
+	}
+	return i, nil
+}
+
+// boolean returns value of boolean option.
+func (v optionValues) boolean(name string) (bool, error) {
+	b, err := strconv.ParseBool(v[name])
+	if err != nil {
+		return false, v.invalid(name)
+	}
+	return b, nil
+}
+
+// list returns value of comma-separated list option, see splitList.
+func (v optionValues) list(name string) []string {
+	return splitList(v[name])
+}
+
+// permissionErrorIgnorer is a Collector of admin-only tables.
+// Permission errors (missing admin rights) are logged only at debug level;
+// if permissions are insufficient, collection is skipped and no error is reported.
+type permissionErrorIgnorer interface {
+	ignorePermissionError() bool
+}
+
+// labeledCollector is a Collector which collector label value differs from collect.<name>.
+type labeledCollector interface {
+	labelValue() string
+}
+
+// scrapeFunc collects metrics from ProxySQL. Queries must be canceled when ctx is done.
+type scrapeFunc func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error
+
+// collector implements Collector with scrape function.
+type collector struct {
+	name           string
+	help           string
+	defaultEnabled bool
+	minVersion     string
+	tables         []string
+	adminOnly      bool
+	scrape         scrapeFunc
+	// options are passed to scrapeWithOptions which returns scrape function configured with their values.
+	options           []collectorOption
+	scrapeWithOptions func(values optionValues) (scrapeFunc, error)
+	// label, if not empty, overrides collect.<name> collector label value for compatibility with older exporter versions.
+	label string
+}
+
+// Name implements Collector.
+func (c *collector) Name() string { return c.name }
+
+// Help implements Collector.
+func (c *collector) Help() string { return c.help }
+
+// DefaultEnabled implements Collector.
+func (c *collector) DefaultEnabled() bool { return c.defaultEnabled }
+
+// MinVersion implements Collector.
+func (c *collector) MinVersion() string { return c.minVersion }
+
+// Tables implements Collector.
+func (c *collector) Tables() []string { return c.tables }
+
+// Scrape implements Collector.
+func (c *collector) Scrape(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	if c.scrape == nil {
+		return errors.New("collector is not configured")
+	}
+	return c.scrape(ctx, db, ch)
+}
+
+func (c *collector) collectorOptions() []collectorOption { return c.options }
+
+func (c *collector) configure(values optionValues) (Collector, error) {
+	if c.scrapeWithOptions == nil {
+		return c, nil
+	}
+	scrape, err := c.scrapeWithOptions(values)
+	if err != nil {
+		return nil, err
+	}
+	configured := *c
+	configured.scrape = scrape
+	return &configured, nil
+}
+
+func (c *collector) ignorePermissionError() bool { return c.adminOnly }
+
+func (c *collector) labelValue() string { return c.label }
+
+// collectors are all known collectors in the documentation order.
+var collectors = registerCollectors(
+	&collector{
+		name:           "mysql_status",
+		help:           "Collect from stats_mysql_global (SHOW MYSQL STATUS).",
+		tables:         []string{"stats.stats_mysql_global"},
+		defaultEnabled: true,
+		scrape:         scrapeMySQLGlobal,
+	},
+	&collector{
+		name:           "mysql_connection_pool",
+		help:           "Collect from stats_mysql_connection_pool.",
+		tables:         []string{"stats.stats_mysql_connection_pool"},
+		defaultEnabled: true,
+		scrape:         scrapeMySQLConnectionPool,
+	},
+	&collector{
+		name:           "mysql_connection_list",
+		help:           "Collect connection list from stats_mysql_processlist.",
+		tables:         []string{"stats.stats_mysql_processlist"},
+		defaultEnabled: true,
+		scrape:         scrapeMySQLConnectionList,
+	},
+	&collector{
+		name:   "detailed.stats_mysql_processlist",
+		help:   "Collect detailed connection list from stats_mysql_processlist.",
+		tables: []string{"stats.stats_mysql_processlist"},
+		label:  "collect.stats_mysql_processlist",
+		scrape: scrapeDetailedMySQLConnectionList,
+	},
+	&collector{
+		name:   "sessions.stats_mysql_processlist",
+		help:   "Collect session counts by command and backend, active session time histogram and oldest running query age from stats_mysql_processlist.",
+		tables: []string{"stats.stats_mysql_processlist"},
+		scrape: scrapeMySQLSessions,
+	},
+	&collector{
+		name:       "runtime_mysql_servers",
+		help:       "Collect from runtime_mysql_servers - need admin credentials.",
+		tables:     []string{"main.runtime_mysql_servers"},
+		minVersion: "2.0.0",
+		adminOnly:  true,
+		scrape:     scrapeMySQLRuntimeServers,
+	},
+	&collector{
+		name:       "stats_memory_metrics",
+		help:       "Collect memory metrics from stats_memory_metrics.",
+		tables:     []string{"stats.stats_memory_metrics"},
+		minVersion: "1.4.4",
+		scrape:     scrapeMemoryMetrics,
+	},
+	&collector{
+		name:   "stats_command_counter",
+		help:   "Collect histograms over command latency from stats_mysql_commands_counters.",
+		tables: []string{"stats.stats_mysql_commands_counters"},
+		label:  "collect.stats_command_counter_metrics",
+		options: []collectorOption{
+			{name: "commands", defValue: "all", help: "Comma-separated list of commands to collect from stats_mysql_commands_counters, or \"all\"."},
+			{name: "exclude", help: "Comma-separated list of commands to exclude from stats_mysql_commands_counters."},
+			{name: "regex", help: "Regular expression commands from stats_mysql_commands_counters must match to be collected."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			filter, err := newCommandCounterFilter(values["commands"], values["exclude"], values["regex"])
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapeMySQLCommandCounterMetrics(ctx, db, ch, filter)
+			}, nil
+		},
+	},
+	&collector{
+		name:   "stats_mysql_query_digest",
+		help:   "Collect top-N query digests from stats_mysql_query_digest.",
+		tables: []string{"stats.stats_mysql_query_digest"},
+		options: []collectorOption{
+			{name: "limit", defValue: "100", help: "Maximum number of digests to collect from stats_mysql_query_digest."},
+			{name: "order_by", defValue: "sum_time", help: "Column used to select top-N digests from stats_mysql_query_digest: sum_time or count_star."},
+			{name: "schemanames", help: "Comma-separated list of schemas to collect digests for from stats_mysql_query_digest; all if empty."},
+			{name: "usernames", help: "Comma-separated list of users to collect digests for from stats_mysql_query_digest; all if empty."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			limit, err := values.positiveInt("limit")
+			if err != nil {
+				return nil, err
+			}
+			orderBy := values["order_by"]
+			if !mySQLQueryDigestOrderBy[orderBy] {
+				return nil, values.invalid("order_by")
+			}
+			schemanames, usernames := values.list("schemanames"), values.list("usernames")
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapeMySQLQueryDigest(ctx, db, ch, limit, orderBy, schemanames, usernames)
+			}, nil
+		},
+	},
+	&collector{
+		name:   "stats_mysql_query_rules",
+		help:   "Collect query rules hits from stats_mysql_query_rules; labels from runtime_mysql_query_rules need admin credentials.",
+		tables: []string{"stats.stats_mysql_query_rules"},
+		scrape: scrapeMySQLQueryRules,
+	},
+	&collector{
+		name:       "stats_mysql_errors",
+		help:       "Collect backend errors from stats_mysql_errors.",
+		tables:     []string{"stats.stats_mysql_errors"},
+		minVersion: "2.0.0",
+		scrape:     scrapeMySQLErrors,
+	},
+	&collector{
+		name: "monitor",
+		help: "Collect the most recent Monitor module checks from monitor.mysql_server_*_log - need admin credentials.",
+		tables: []string{
+			"monitor.mysql_server_ping_log",
+			"monitor.mysql_server_connect_log",
+			"monitor.mysql_server_read_only_log",
+			"monitor.mysql_server_replication_lag_log",
+		},
+		adminOnly: true,
+		scrape:    scrapeMonitor,
+	},
+	&collector{
+		name: "group_replication",
+		help: "Collect from runtime_mysql_group_replication_hostgroups and monitor.mysql_server_group_replication_log - need admin credentials.",
+		tables: []string{
+			"main.runtime_mysql_group_replication_hostgroups",
+			"monitor.mysql_server_group_replication_log",
+		},
+		minVersion: "1.4.0",
+		adminOnly:  true,
+		scrape:     scrapeGroupReplication,
+	},
+	&collector{
+		name: "galera",
+		help: "Collect from runtime_mysql_galera_hostgroups and monitor.mysql_server_galera_log - need admin credentials.",
+		tables: []string{
+			"main.runtime_mysql_galera_hostgroups",
+			"monitor.mysql_server_galera_log",
+		},
+		minVersion: "2.0.0",
+		adminOnly:  true,
+		scrape:     scrapeGalera,
+	},
+	&collector{
+		name: "aws_aurora",
+		help: "Collect from runtime_mysql_aws_aurora_hostgroups and monitor.mysql_server_aws_aurora_log - need admin credentials.",
+		tables: []string{
+			"main.runtime_mysql_aws_aurora_hostgroups",
+			"monitor.mysql_server_aws_aurora_log",
+		},
+		minVersion: "2.0.0",
+		adminOnly:  true,
+		scrape:     scrapeAWSAurora,
+	},
+	&collector{
+		name: "proxysql_cluster",
+		help: "Collect ProxySQL Cluster peers checksums and metrics from stats_proxysql_servers_*; checksum comparison needs admin credentials.",
+		tables: []string{
+			"stats.stats_proxysql_servers_checksums",
+			"stats.stats_proxysql_servers_metrics",
+			"stats.stats_proxysql_servers_status",
+		},
+		minVersion: "1.4.0",
+		scrape:     scrapeProxySQLCluster,
+	},
+	&collector{
+		name:   "stats_mysql_users",
+		help:   "Collect per-user frontend connections from stats_mysql_users.",
+		tables: []string{"stats.stats_mysql_users"},
+		options: []collectorOption{
+			{name: "runtime", defValue: "false", isBool: true, help: "Collect default hostgroup and flags of users from runtime_mysql_users - need admin credentials."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			runtime, err := values.boolean("runtime")
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapeMySQLUsers(ctx, db, ch, runtime)
+			}, nil
+		},
+	},
+	&collector{
+		name:       "stats_mysql_free_connections",
+		help:       "Collect free connections count, age and statistics per endpoint from stats_mysql_free_connections.",
+		tables:     []string{"stats.stats_mysql_free_connections"},
+		minVersion: "2.0.0",
+		scrape:     scrapeMySQLFreeConnections,
+	},
+	&collector{
+		name:       "stats_mysql_prepared_statements_info",
+		help:       "Collect prepared statements counts per hostgroup, schema and user from stats_mysql_prepared_statements_info.",
+		tables:     []string{"stats.stats_mysql_prepared_statements_info"},
+		minVersion: "2.0.0",
+		scrape:     scrapeMySQLPreparedStatements,
+	},
+	&collector{
+		name:       "stats_mysql_client_host_cache",
+		help:       "Collect per-client connection errors from stats_mysql_client_host_cache; blocked clients count needs admin credentials.",
+		tables:     []string{"stats.stats_mysql_client_host_cache"},
+		minVersion: "2.0.0",
+		options: []collectorOption{
+			{name: "limit", defValue: "100", help: "Maximum number of client addresses with the most errors to collect from stats_mysql_client_host_cache."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			limit, err := values.positiveInt("limit")
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapeMySQLClientHostCache(ctx, db, ch, limit)
+			}, nil
+		},
+	},
+	&collector{
+		name:      "runtime_global_variables",
+		help:      "Collect numeric and boolean global variables from runtime_global_variables - need admin credentials.",
+		tables:    []string{"main.runtime_global_variables"},
+		adminOnly: true,
+		options: []collectorOption{
+			{name: "names", defValue: strings.Join(defaultGlobalVariables, ","), docDefault: "is listed below", help: "Comma-separated list of variables to collect from runtime_global_variables, or \"all\"."},
+			{name: "regex", help: "Regular expression variables from runtime_global_variables must match to be collected in addition to listed ones."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			filter, err := newGlobalVariablesFilter(values["names"], values["regex"])
+			if err != nil {
+				return nil, err
+			}
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapeGlobalVariables(ctx, db, ch, filter)
+			}, nil
+		},
+	},
+	&collector{
+		name: "config_drift",
+		help: "Collect whether memory configuration is not loaded to runtime or saved to disk, and runtime_checksums_values - need admin credentials.",
+		tables: []string{
+			"main.mysql_servers",
+			"main.runtime_mysql_servers",
+			"main.mysql_query_rules",
+			"main.runtime_mysql_query_rules",
+			"main.mysql_users",
+			"main.runtime_mysql_users",
+			"main.proxysql_servers",
+			"main.runtime_proxysql_servers",
+			"main.global_variables",
+			"main.runtime_global_variables",
+			"main.runtime_checksums_values",
+			"disk.mysql_servers",
+			"disk.mysql_query_rules",
+			"disk.mysql_users",
+			"disk.proxysql_servers",
+			"disk.global_variables",
+		},
+		minVersion: "1.4.0",
+		adminOnly:  true,
+		scrape:     scrapeConfigDrift,
+	},
+	&collector{
+		name:       "pgsql_status",
+		help:       "Collect from stats_pgsql_global.",
+		tables:     []string{"stats.stats_pgsql_global"},
+		minVersion: "3.0.0",
+		scrape:     scrapePgSQLGlobal,
+	},
+	&collector{
+		name:       "pgsql_connection_pool",
+		help:       "Collect from stats_pgsql_connection_pool.",
+		tables:     []string{"stats.stats_pgsql_connection_pool"},
+		minVersion: "3.0.0",
+		scrape:     scrapePgSQLConnectionPool,
+	},
+	&collector{
+		name:       "detailed.stats_pgsql_processlist",
+		help:       "Collect detailed connection list from stats_pgsql_processlist.",
+		tables:     []string{"stats.stats_pgsql_processlist"},
+		minVersion: "3.0.0",
+		scrape:     scrapePgSQLProcessList,
+	},
+	&collector{
+		name:       "stats_pgsql_query_digest",
+		help:       "Collect top-N query digests from stats_pgsql_query_digest.",
+		tables:     []string{"stats.stats_pgsql_query_digest"},
+		minVersion: "3.0.0",
+		options: []collectorOption{
+			{name: "limit", defValue: "100", help: "Maximum number of digests to collect from stats_pgsql_query_digest."},
+			{name: "order_by", defValue: "sum_time", help: "Column used to select top-N digests from stats_pgsql_query_digest: sum_time or count_star."},
+		},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			limit, err := values.positiveInt("limit")
+			if err != nil {
+				return nil, err
+			}
+			orderBy := values["order_by"]
+			if !mySQLQueryDigestOrderBy[orderBy] {
+				return nil, values.invalid("order_by")
+			}
+			return func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+				return scrapePgSQLQueryDigest(ctx, db, ch, limit, orderBy)
+			}, nil
+		},
+	},
+	&collector{
+		name:       "runtime_pgsql_servers",
+		help:       "Collect from runtime_pgsql_servers - need admin credentials.",
+		tables:     []string{"main.runtime_pgsql_servers"},
+		minVersion: "3.0.0",
+		adminOnly:  true,
+		scrape:     scrapePgSQLRuntimeServers,
+	},
+	&collector{
+		name:           "proxysql_info",
+		help:           "Collect ProxySQL version from global_variables.",
+		tables:         []string{"main.global_variables"},
+		defaultEnabled: true,
+		scrape:         scrapeProxySQLInfo,
+	},
+)
+
+// registerCollectors checks that collector names are unique and minimum versions are valid, and returns collectors.
+func registerCollectors(cs ...Collector) []Collector {
+	names := make(map[string]bool, len(cs))
+	for _, c := range cs {
+		if names[c.Name()] {
+			panic(fmt.Sprintf("collector %q is already registered", c.Name()))
+		}
+		names[c.Name()] = true
+		if v := c.MinVersion(); v != "" {
+			if _, err := parseProxySQLVersion(v); err != nil {
+				panic(fmt.Sprintf("collector %q: %s", c.Name(), err))
+			}
+		}
+	}
+	return cs
+}
+
+// collectorLabel returns collector label value of exporter metrics.
+func collectorLabel(c Collector) string {
+	if l, ok := c.(labeledCollector); ok && l.labelValue() != "" {
+		return l.labelValue()
+	}
+	return "collect." + c.Name()
+}
+
+// collectorFlags adds collect.<name> flag for every collector to fs and returns flag values by collector name.
+func collectorFlags(fs *flag.FlagSet, cs []Collector) map[string]*bool {
+	res := make(map[string]*bool, len(cs))
+	for _, c := range cs {
+		help := c.Help()
+		if v := c.MinVersion(); v != "" {
+			help += " Requires ProxySQL " + v + " or newer."
+		}
+		res[c.Name()] = fs.Bool("collect."+c.Name(), c.DefaultEnabled(), help)
+	}
+	return res
+}
+
+// optionFlag is a flag.Value of collector option.
+type optionFlag struct {
+	value  string
+	isBool bool
+}
+
+// String implements flag.Value.
+func (f *optionFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.value
+}
+
+// Set implements flag.Value.
+func (f *optionFlag) Set(s string) error {
+	if f.isBool {
+		if _, err := strconv.ParseBool(s); err != nil {
+			return err
+		}
+	}
+	f.value = s
+	return nil
+}
+
+// IsBoolFlag allows boolean options to be set by --collect.<name>.<option> without value.
+func (f *optionFlag) IsBoolFlag() bool { return f.isBool }
+
+// collectorOptionFlags adds collect.<name>.<option> flag for every option of configurable collectors to fs
+// and returns option values by collector and option name.
+func collectorOptionFlags(fs *flag.FlagSet, cs []Collector) map[string]map[string]*optionFlag {
+	res := make(map[string]map[string]*optionFlag)
+	for _, c := range cs {
+		cc, ok := c.(configurableCollector)
+		if !ok || len(cc.collectorOptions()) == 0 {
+			continue
+		}
+		res[c.Name()] = make(map[string]*optionFlag)
+		for _, o := range cc.collectorOptions() {
+			f := &optionFlag{value: o.defValue, isBool: o.isBool}
+			fs.Var(f, "collect."+c.Name()+"."+o.name, o.help)
+			res[c.Name()][o.name] = f
+		}
+	}
+	return res
+}
+
+// configureCollector returns collector configured with its option values from opts;
+// missing values are set to defaults.
+func configureCollector(c Collector, opts ExporterOptions) (Collector, error) {
+	cc, ok := c.(configurableCollector)
+	if !ok {
+		return c, nil
+	}
+	values := make(optionValues)
+	for _, o := range cc.collectorOptions() {
+		values[o.name] = o.defValue
+		if v, ok := opts.CollectorOptions[c.Name()][o.name]; ok {
+			values[o.name] = v
+		}
+	}
+	configured, err := cc.configure(values)
+	if err != nil {
+		return nil, fmt.Errorf("collector %s: %w", c.Name(), err)
+	}
+	return configured, nil
+}
+
+// collectorsMarkdown returns Markdown table documenting collectors for README.md.
+func collectorsMarkdown(cs []Collector) string {
+	rows := [][]string{{"Name", "Default", "ProxySQL version", "Description"}, nil}
+	for _, c := range cs {
+		minVersion := c.MinVersion()
+		if minVersion == "" {
+			minVersion = "any"
+		} else {
+			minVersion += "+"
+		}
+		rows = append(rows, []string{"`collect." + c.Name() + "`", strconv.FormatBool(c.DefaultEnabled()), minVersion, c.Help()})
+	}
+	return markdownTable(rows)
+}
+
+// markdownTable returns aligned Markdown table; the first row is the header, nil row is the separator.
+func markdownTable(rows [][]string) string {
+	widths := make([]int, len(rows[0]))
+	for _, row := range rows {
+		for i, cell := range row {
+			widths[i] = max(widths[i], len(cell))
+		}
+	}
+
+	var b strings.Builder
+	for _, row := range rows {
+		cells := make([]string, len(widths))
+		for i, width := range widths {
+			if row == nil {
+				cells[i] = strings.Repeat("-", width)
+			} else {
+				cells[i] = row[i] + strings.Repeat(" ", width-len(row[i]))
+			}
+		}
+		b.WriteString("| " + strings.Join(cells, " | ") + " |\n")
+	}
+	return b.String()
+}
+
+// collectorOptionsMarkdown returns Markdown table documenting collector options for README.md.
+func collectorOptionsMarkdown(cs []Collector) string {
+	rows := [][]string{{"Name", "Description"}, nil}
+	for _, c := range cs {
+		cc, ok := c.(configurableCollector)
+		if !ok {
+			continue
+		}
+		for _, o := range cc.collectorOptions() {
+			help, def := o.help, o.defValue
+			if o.docDefault != "" {
+				def = o.docDefault
+			}
+			if def != "" && !o.isBool {
+				help += " (default " + def + ")"
+			}
+			rows = append(rows, []string{"`collect." + c.Name() + "." + o.name + "`", help})
+		}
+	}
+	return markdownTable(rows)
+}
@@ -0,0 +1,136 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// allCollectors returns names of all registered collectors.
+func allCollectors() map[string]bool {
+	res := make(map[string]bool, len(collectors))
+	for _, c := range collectors {
+		res[c.Name()] = true
+	}
+	return res
+}
+
+func TestRegisterCollectors(t *testing.T) {
+	assert.Panics(t, func() {
+		registerCollectors(&collector{name: "monitor"}, &collector{name: "monitor"})
+	})
+}
+
+func TestCollectorFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := collectorFlags(fs, []Collector{
+		&collector{name: "mysql_status", help: "Collect status.", defaultEnabled: true},
+		&collector{name: "stats_mysql_errors", help: "Collect errors.", minVersion: "2.0.0"},
+	})
+	require.NoError(t, fs.Parse([]string{"--collect.mysql_status=false", "--collect.stats_mysql_errors"}))
+
+	assert.False(t, *flags["mysql_status"])
+	assert.True(t, *flags["stats_mysql_errors"])
+	assert.Equal(t, "true", fs.Lookup("collect.mysql_status").DefValue)
+	assert.Equal(t, "Collect errors. Requires ProxySQL 2.0.0 or newer.", fs.Lookup("collect.stats_mysql_errors").Usage)
+}
+
+func TestExporterScrapers(t *testing.T) {
+	exporter := NewExporter("", ExporterOptions{
+		Collectors:       map[string]bool{"mysql_status": true, "stats_mysql_query_digest": true, "stats_mysql_users": true},
+		CollectorOptions: map[string]optionValues{"stats_mysql_users": {"runtime": "yes"}},
+	})
+
+	// stats_mysql_users with invalid option is skipped
+	scrapers := exporter.scrapers()
+	require.Len(t, scrapers, 2)
+	assert.Equal(t, "collect.mysql_status", collectorLabel(scrapers[0]))
+	assert.Equal(t, "collect.stats_mysql_query_digest", collectorLabel(scrapers[1]))
+
+	// collectors with options are configured, registered ones are not changed
+	assert.NotNil(t, scrapers[1].(*collector).scrape)
+	for _, c := range collectors {
+		if c.Name() == "stats_mysql_query_digest" {
+			assert.Nil(t, c.(*collector).scrape)
+			assert.EqualError(t, c.Scrape(context.Background(), nil, nil), "collector is not configured")
+		}
+	}
+}
+
+func TestCollectorOptionFlags(t *testing.T) {
+	cs := []Collector{
+		&collector{name: "mysql_status"},
+		&collector{name: "stats_mysql_users", options: []collectorOption{
+			{name: "runtime", defValue: "false", isBool: true, help: "Collect runtime."},
+			{name: "limit", defValue: "100", help: "Limit."},
+		}},
+	}
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	flags := collectorOptionFlags(fs, cs)
+	require.NoError(t, fs.Parse([]string{"--collect.stats_mysql_users.runtime", "--collect.stats_mysql_users.limit=10"}))
+
+	require.Len(t, flags, 1)
+	assert.Equal(t, "true", flags["stats_mysql_users"]["runtime"].String())
+	assert.Equal(t, "10", flags["stats_mysql_users"]["limit"].String())
+	assert.Equal(t, "100", fs.Lookup("collect.stats_mysql_users.limit").DefValue)
+	assert.Error(t, fs.Parse([]string{"--collect.stats_mysql_users.runtime=maybe"}))
+}
+
+func TestConfigureCollector(t *testing.T) {
+	var limit int
+	c := &collector{
+		name:    "stats_mysql_client_host_cache",
+		options: []collectorOption{{name: "limit", defValue: "100"}},
+		scrapeWithOptions: func(values optionValues) (scrapeFunc, error) {
+			var err error
+			limit, err = values.positiveInt("limit")
+			return scrapeMySQLGlobal, err
+		},
+	}
+
+	_, err := configureCollector(c, ExporterOptions{})
+	require.NoError(t, err)
+	assert.Equal(t, 100, limit)
+
+	_, err = configureCollector(c, ExporterOptions{CollectorOptions: map[string]optionValues{"stats_mysql_client_host_cache": {"limit": "10"}}})
+	require.NoError(t, err)
+	assert.Equal(t, 10, limit)
+
+	_, err = configureCollector(c, ExporterOptions{CollectorOptions: map[string]optionValues{"stats_mysql_client_host_cache": {"limit": "0"}}})
+	assert.EqualError(t, err, `collector stats_mysql_client_host_cache: not a valid limit option value: "0"`)
+}
+
+func TestCollectorLabel(t *testing.T) {
+	assert.Equal(t, "collect.mysql_status", collectorLabel(&collector{name: "mysql_status"}))
+	assert.Equal(t, "collect.stats_mysql_processlist",
+		collectorLabel(&collector{name: "detailed.stats_mysql_processlist", label: "collect.stats_mysql_processlist"}))
+}
+
+func TestCollectorsREADME(t *testing.T) {
+	b, err := os.ReadFile("README.md")
+	require.NoError(t, err)
+
+	expected := collectorsMarkdown(collectors)
+	assert.True(t, strings.Contains(string(b), expected), "README.md collectors table is outdated, expected:\n%s", expected)
+	expected = collectorOptionsMarkdown(collectors)
+	assert.True(t, strings.Contains(string(b), expected), "README.md collector flags table is outdated, expected:\n%s", expected)
+}
@@ -0,0 +1,241 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/go-sql-driver/mysql"
+	"gopkg.in/yaml.v2"
+)
+
+// Config is the exporter configuration file.
+// Command-line flags override values from it.
+type Config struct {
+	// ProxySQL is the admin interface of ProxySQL scraped by /metrics endpoint.
+	ProxySQL ProxySQLConfig `yaml:"proxysql"`
+	// Collectors enable or disable collectors by name of their flag without "collect." prefix,
+	// e.g. "mysql_status" or "stats_mysql_query_digest".
+	Collectors map[string]bool `yaml:"collectors"`
+	// CollectorOptions are collector-specific options by collector and option name of their flags,
+	// e.g. "stats_mysql_query_digest" and "limit" for collect.stats_mysql_query_digest.limit flag.
+	// Values are strings, numbers, booleans or lists for comma-separated flag values.
+	CollectorOptions map[string]map[string]interface{} `yaml:"collector_options"`
+	// AuthModules are named credentials used by the multi-target probe endpoint.
+	AuthModules map[string]AuthModule `yaml:"auth_modules"`
+}
+
+// ProxySQLConfig describes connection to ProxySQL admin interface
+// either with DSN or with separate fields.
+type ProxySQLConfig struct {
+	DSN string `yaml:"dsn"`
+	// Address is host:port of admin interface; alternatively, Host and Port can be used.
+	Address      string    `yaml:"address"`
+	Host         string    `yaml:"host"`
+	Port         int       `yaml:"port"`
+	User         string    `yaml:"user"`
+	PasswordFile string    `yaml:"password_file"`
+	TLS          TLSConfig `yaml:"tls"`
+}
+
+// TLSConfig describes TLS connection to ProxySQL admin interface.
+type TLSConfig struct {
+	CAFile             string `yaml:"ca_file"`
+	CertFile           string `yaml:"cert_file"`
+	KeyFile            string `yaml:"key_file"`
+	ServerName         string `yaml:"server_name"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+}
+
+// AuthModule contains credentials for ProxySQL admin interface of probe targets.
+type AuthModule struct {
+	Username string `yaml:"username"`
+	Password string `yaml:"password"`
+	// Params are additional DSN parameters, e.g. timeout or tls,
+	// see https://github.com/go-sql-driver/mysql#parameters.
+	Params map[string]string `yaml:"params"`
+}
+
+// loadConfig reads and validates configuration file.
+func loadConfig(path string) (*Config, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &Config{}
+	if err = yaml.UnmarshalStrict(b, cfg); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	p := cfg.ProxySQL
+	if p.DSN != "" && (p.Address != "" || p.Host != "" || p.Port != 0 || p.User != "" || p.PasswordFile != "") {
+		return nil, errors.New("proxysql: dsn can't be used together with address, host, port, user and password_file")
+	}
+	if p.Address != "" && (p.Host != "" || p.Port != 0) {
+		return nil, errors.New("proxysql: address can't be used together with host and port")
+	}
+	for name, module := range cfg.AuthModules {
+		if module.Username == "" {
+			return nil, fmt.Errorf("auth module %q: username is missing", name)
+		}
+	}
+	return cfg, nil
+}
+
+// applyToFlags sets collect.* flags from collectors and collector options, or resets them to default values
+// if configuration does not contain them. Flags set on the command line (cmdline) are not changed,
+// so they override configuration.
+func (c *Config) applyToFlags(fs *flag.FlagSet, cmdline map[string]bool) error {
+	values := make(map[string]string)
+	for name, enabled := range c.Collectors {
+		f := fs.Lookup("collect." + name)
+		if f == nil {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+		if b, ok := f.Value.(interface{ IsBoolFlag() bool }); !ok || !b.IsBoolFlag() || isOptionFlag(f) {
+			return fmt.Errorf("unknown collector %q", name)
+		}
+		values["collect."+name] = strconv.FormatBool(enabled)
+	}
+
+	for name, options := range c.CollectorOptions {
+		for option, value := range options {
+			flagName := "collect." + name + "." + option
+			if f := fs.Lookup(flagName); f == nil || !isOptionFlag(f) {
+				return fmt.Errorf("unknown collector option %q", name+"."+option)
+			}
+			v, err := optionValueString(value)
+			if err != nil {
+				return fmt.Errorf("collector option %q: %w", name+"."+option, err)
+			}
+			values[flagName] = v
+		}
+	}
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+		if err != nil || !strings.HasPrefix(f.Name, "collect.") || cmdline[f.Name] {
+			return
+		}
+		value, ok := values[f.Name]
+		if !ok {
+			value = f.DefValue
+		}
+		if e := fs.Set(f.Name, value); e != nil {
+			err = fmt.Errorf("%s: %w", f.Name, e)
+		}
+	})
+	return err
+}
+
+// isOptionFlag returns true if f is collect.<name>.<option> flag of collector option.
+func isOptionFlag(f *flag.Flag) bool {
+	_, ok := f.Value.(*optionFlag)
+	return ok
+}
+
+// optionValueString returns flag value for collector option value from configuration file.
+// Lists are joined with commas.
+func optionValueString(value interface{}) (string, error) {
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case int, float64, bool:
+		return fmt.Sprint(v), nil
+	case []interface{}:
+		elems := make([]string, len(v))
+		for i, e := range v {
+			if _, ok := e.([]interface{}); ok {
+				return "", errors.New("nested lists are not supported")
+			}
+			s, err := optionValueString(e)
+			if err != nil {
+				return "", err
+			}
+			elems[i] = s
+		}
+		return strings.Join(elems, ","), nil
+	default:
+		return "", fmt.Errorf("unsupported value %v", v)
+	}
+}
+
+// dsn returns data source name for ProxySQL admin interface, or empty string if it is not configured.
+// The password is not included: it is read from PasswordFile by Exporter for every new connection.
+func (p ProxySQLConfig) dsn() (string, error) {
+	addr := p.Address
+	if p.Host != "" {
+		addr = p.Host
+		if p.Port != 0 {
+			addr = net.JoinHostPort(p.Host, strconv.Itoa(p.Port))
+		}
+	}
+	if p.DSN != "" || addr == "" {
+		return p.DSN, nil
+	}
+
+	cfg := mysql.NewConfig()
+	cfg.User = p.User
+	cfg.Net = "tcp"
+	cfg.Addr = addr
+	if p.PasswordFile != "" {
+		// check that the file is readable
+		if _, err := readPasswordFile(p.PasswordFile); err != nil {
+			return "", err
+		}
+	}
+
+	return cfg.FormatDSN(), nil
+}
+
+// readPasswordFile returns password from the file without trailing newline.
+func readPasswordFile(path string) (string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// redactDSN returns DSN with password replaced, suitable for logging.
+func redactDSN(dsn string) string {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "<invalid DSN>"
+	}
+	if cfg.Passwd != "" {
+		cfg.Passwd = "xxxxx"
+	}
+	return cfg.FormatDSN()
+}
+
+// dsn returns data source name for the given target (host:port) with module credentials.
+func (m AuthModule) dsn(target string) string {
+	cfg := mysql.NewConfig()
+	cfg.User = m.Username
+	cfg.Passwd = m.Password
+	cfg.Net = "tcp"
+	cfg.Addr = target
+	cfg.Params = m.Params
+	return cfg.FormatDSN()
+}
@@ -0,0 +1,310 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProxySQL keeps configuration in three layers: memory (main schema tables edited by operators),
+// runtime (runtime_* tables actually used) and disk (disk schema tables loaded on restart).
+// Changes must be propagated with LOAD ... TO RUNTIME and SAVE ... TO DISK commands.
+
+// configModule describes tables of a single configuration module in all three layers.
+type configModule struct {
+	// name is the module name as in runtime_checksums_values.
+	name                  string
+	memory, runtime, disk string
+	// runtimeRow, if not nil, returns function applied to memory and runtime rows before they are compared
+	// to ignore values ProxySQL changes on load (e.g. hashes passwords) or at runtime.
+	runtimeRow func(ctx context.Context, db *sql.DB) (configRowFunc, error)
+}
+
+// configRowFunc changes row values by column name before comparison; deleted columns are not compared.
+type configRowFunc func(row map[string]string)
+
+// configModules are compared by scrapeConfigDrift.
+var configModules = []configModule{
+	{
+		name:       "mysql_servers",
+		memory:     "SELECT * FROM main.mysql_servers",
+		runtime:    "SELECT * FROM main.runtime_mysql_servers",
+		disk:       "SELECT * FROM disk.mysql_servers",
+		runtimeRow: runtimeMySQLServersRow,
+	},
+	{
+		name:    "mysql_query_rules",
+		memory:  "SELECT * FROM main.mysql_query_rules",
+		runtime: "SELECT * FROM main.runtime_mysql_query_rules",
+		disk:    "SELECT * FROM disk.mysql_query_rules",
+	},
+	{
+		name:    "mysql_users",
+		memory:  "SELECT * FROM main.mysql_users",
+		runtime: "SELECT * FROM main.runtime_mysql_users",
+		disk:    "SELECT * FROM disk.mysql_users",
+		// runtime contains hashed passwords and separate rows for frontend and backend users,
+		// so password changes are never reported as pending
+		runtimeRow: ignoreConfigColumns("password", "frontend", "backend"),
+	},
+	{
+		name:    "proxysql_servers",
+		memory:  "SELECT * FROM main.proxysql_servers",
+		runtime: "SELECT * FROM main.runtime_proxysql_servers",
+		disk:    "SELECT * FROM disk.proxysql_servers",
+	},
+	{
+		name:    "mysql_variables",
+		memory:  "SELECT * FROM main.global_variables WHERE variable_name LIKE 'mysql-%'",
+		runtime: "SELECT * FROM main.runtime_global_variables WHERE variable_name LIKE 'mysql-%'",
+		disk:    "SELECT * FROM disk.global_variables WHERE variable_name LIKE 'mysql-%'",
+	},
+	{
+		// admin-version is updated in memory on upgrade, but not on disk
+		name:    "admin_variables",
+		memory:  "SELECT * FROM main.global_variables WHERE variable_name LIKE 'admin-%' AND variable_name != 'admin-version'",
+		runtime: "SELECT * FROM main.runtime_global_variables WHERE variable_name LIKE 'admin-%' AND variable_name != 'admin-version'",
+		disk:    "SELECT * FROM disk.global_variables WHERE variable_name LIKE 'admin-%' AND variable_name != 'admin-version'",
+	},
+}
+
+// managedHostgroupsQueries return hostgroups between which ProxySQL moves servers at runtime
+// by replication, group replication, Galera and Aurora monitoring; queries of missing main schema tables are skipped.
+var managedHostgroupsQueries = []struct{ table, query string }{
+	{"mysql_replication_hostgroups", "SELECT writer_hostgroup, reader_hostgroup FROM main.mysql_replication_hostgroups"},
+	{"mysql_group_replication_hostgroups", "SELECT writer_hostgroup, backup_writer_hostgroup, reader_hostgroup, offline_hostgroup FROM main.mysql_group_replication_hostgroups"},
+	{"mysql_galera_hostgroups", "SELECT writer_hostgroup, backup_writer_hostgroup, reader_hostgroup, offline_hostgroup FROM main.mysql_galera_hostgroups"},
+	{"mysql_aws_aurora_hostgroups", "SELECT writer_hostgroup, reader_hostgroup FROM main.mysql_aws_aurora_hostgroups"},
+}
+
+const configChecksumsQuery = "SELECT name, version, epoch FROM runtime_checksums_values"
+
+var (
+	configPendingRuntimeLoadDesc = newDesc("config", "pending_runtime_load",
+		"Whether memory configuration of the module differs from runtime one and needs LOAD ... TO RUNTIME (1 for pending, 0 for loaded).",
+		[]string{"module"})
+	configUnsavedToDiskDesc = newDesc("config", "unsaved_to_disk",
+		"Whether memory configuration of the module differs from disk one and needs SAVE ... TO DISK (1 for unsaved, 0 for saved).",
+		[]string{"module"})
+	configChecksumVersionDesc = newDesc("config", "checksum_version",
+		"The version of the module runtime configuration from runtime_checksums_values; incremented on every load to runtime.",
+		[]string{"module"})
+	configChecksumEpochDesc = newDesc("config", "checksum_epoch",
+		"The Unix timestamp of the module runtime configuration load from runtime_checksums_values.",
+		[]string{"module"})
+)
+
+// scrapeConfigDrift compares memory configuration of configModules with runtime and disk ones,
+// and collects versions of runtime configuration from `runtime_checksums_values`.
+func scrapeConfigDrift(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	for _, m := range configModules {
+		var runtimeRow configRowFunc
+		if m.runtimeRow != nil {
+			var err error
+			if runtimeRow, err = m.runtimeRow(ctx, db); err != nil {
+				return err
+			}
+		}
+
+		memory, err := queryConfigRows(ctx, db, m.memory, nil, runtimeRow)
+		if err != nil {
+			return err
+		}
+		runtime, err := queryConfigRows(ctx, db, m.runtime, runtimeRow)
+		if err != nil {
+			return err
+		}
+		disk, err := queryConfigRows(ctx, db, m.disk, nil)
+		if err != nil {
+			return err
+		}
+
+		ch <- prometheus.MustNewConstMetric(configPendingRuntimeLoadDesc, prometheus.GaugeValue,
+			configRowsDiffer(memory[1], runtime[0]), m.name)
+		ch <- prometheus.MustNewConstMetric(configUnsavedToDiskDesc, prometheus.GaugeValue,
+			configRowsDiffer(memory[0], disk[0]), m.name)
+	}
+
+	rows, err := db.QueryContext(ctx, configChecksumsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var module string
+		var version, epoch float64
+		if err = rows.Scan(&module, &version, &epoch); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(configChecksumVersionDesc, prometheus.GaugeValue, version, module)
+		ch <- prometheus.MustNewConstMetric(configChecksumEpochDesc, prometheus.GaugeValue, epoch, module)
+	}
+	return rows.Err()
+}
+
+// ignoreConfigColumns returns runtimeRow function of configModule which deletes columns.
+func ignoreConfigColumns(columns ...string) func(ctx context.Context, db *sql.DB) (configRowFunc, error) {
+	return func(context.Context, *sql.DB) (configRowFunc, error) {
+		return func(row map[string]string) {
+			for _, c := range columns {
+				delete(row, c)
+			}
+		}, nil
+	}
+}
+
+// runtimeMySQLServersRow returns function which ignores mysql_servers changes made by ProxySQL itself at runtime:
+// servers are shunned and brought back online, and servers of hostgroups returned by managedHostgroupsQueries
+// are moved between writer, reader and offline hostgroups and their status is changed (e.g. to OFFLINE_SOFT).
+func runtimeMySQLServersRow(ctx context.Context, db *sql.DB) (configRowFunc, error) {
+	// Galera and Aurora tables do not exist in older ProxySQL versions
+	tables, err := queryTables(ctx, db, "main")
+	if err != nil {
+		return nil, err
+	}
+
+	managed := make(map[string]bool)
+	for _, q := range managedHostgroupsQueries {
+		if !slices.Contains(tables, q.table) {
+			continue
+		}
+		hostgroups, err := queryConfigValues(ctx, db, q.query)
+		if err != nil {
+			return nil, err
+		}
+		for _, hg := range hostgroups {
+			managed[hg] = true
+		}
+	}
+
+	return func(row map[string]string) {
+		if managed[row["hostgroup_id"]] {
+			delete(row, "hostgroup_id")
+			delete(row, "status")
+			return
+		}
+		if strings.HasPrefix(row["status"], "SHUNNED") {
+			row["status"] = "ONLINE"
+		}
+	}, nil
+}
+
+// queryConfigValues returns not NULL values of all columns returned by query.
+func queryConfigValues(ctx context.Context, db *sql.DB, query string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	scan := make([]interface{}, len(columns))
+	for i := range scan {
+		scan[i] = new(sql.NullString)
+	}
+
+	var res []string
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return nil, err
+		}
+		for _, v := range scan {
+			if v := v.(*sql.NullString); v.Valid {
+				res = append(res, v.String)
+			}
+		}
+	}
+	return res, rows.Err()
+}
+
+// queryConfigRows returns sets of rows returned by query, one for every function changing rows (nil for none).
+// Every row is represented by its column names and values in columns order sorted by name,
+// so the order of rows and columns does not matter.
+func queryConfigRows(ctx context.Context, db *sql.DB, query string, funcs ...configRowFunc) ([]map[string]bool, error) {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, err
+	}
+	for i := range columns {
+		columns[i] = strings.ToLower(columns[i])
+	}
+	sorted := slices.Sorted(slices.Values(columns))
+
+	scan := make([]interface{}, len(columns))
+	for i := range scan {
+		scan[i] = new(sql.NullString)
+	}
+
+	res := make([]map[string]bool, len(funcs))
+	for k := range res {
+		res[k] = make(map[string]bool)
+	}
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return nil, err
+		}
+
+		for k, f := range funcs {
+			row := make(map[string]string, len(columns))
+			for i, c := range columns {
+				v := scan[i].(*sql.NullString)
+				row[c] = v.String
+				if !v.Valid {
+					row[c] = "\x01NULL"
+				}
+			}
+			if f != nil {
+				f(row)
+			}
+
+			values := make([]string, 0, len(row))
+			for _, c := range sorted {
+				if v, ok := row[c]; ok {
+					values = append(values, c+"="+v)
+				}
+			}
+			res[k][strings.Join(values, "\x00")] = true
+		}
+	}
+	return res, rows.Err()
+}
+
+// configRowsDiffer returns 1 if sets of rows differ, 0 otherwise.
+func configRowsDiffer(a, b map[string]bool) float64 {
+	if len(a) != len(b) {
+		return 1
+	}
+	for row := range a {
+		if !b[row] {
+			return 1
+		}
+	}
+	return 0
+}
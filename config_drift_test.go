@@ -0,0 +1,161 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestScrapeConfigDrift(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	servers := []string{"hostgroup_id", "hostname", "port", "status", "comment"}
+	users := []string{"username", "password", "frontend", "backend"}
+	variables := []string{"variable_name", "variable_value"}
+	for _, m := range configModules {
+		switch m.name {
+		case "mysql_servers":
+			// ProxySQL moves servers between replication hostgroups 10 and 20 and changes their status,
+			// Galera table does not exist in older versions
+			mock.ExpectQuery("SHOW TABLES FROM main").WillReturnRows(sqlmock.NewRows([]string{"tables"}).
+				AddRow("mysql_servers").
+				AddRow("mysql_replication_hostgroups").
+				AddRow("mysql_group_replication_hostgroups").
+				AddRow("mysql_aws_aurora_hostgroups"))
+			mock.ExpectQuery(sanitizeQuery(managedHostgroupsQueries[0].query)).WillReturnRows(sqlmock.NewRows([]string{"writer_hostgroup", "reader_hostgroup"}).
+				AddRow("10", "20"))
+			mock.ExpectQuery(sanitizeQuery(managedHostgroupsQueries[1].query)).WillReturnRows(sqlmock.NewRows([]string{"writer_hostgroup"}))
+			mock.ExpectQuery(sanitizeQuery(managedHostgroupsQueries[3].query)).WillReturnRows(sqlmock.NewRows([]string{"writer_hostgroup"}))
+
+			// shunned server and moved writer are not pending changes; column order does not matter
+			mock.ExpectQuery(sanitizeQuery(m.memory)).WillReturnRows(sqlmock.NewRows(servers).
+				AddRow("10", "db-1", "3306", "ONLINE", nil).
+				AddRow("10", "db-2", "3306", "ONLINE", nil).
+				AddRow("30", "db-3", "3306", "OFFLINE_SOFT", "maintenance").
+				AddRow("30", "db-4", "3306", "ONLINE", nil))
+			mock.ExpectQuery(sanitizeQuery(m.runtime)).WillReturnRows(sqlmock.NewRows([]string{"status", "hostgroup_id", "hostname", "port", "comment"}).
+				AddRow("OFFLINE_SOFT", "30", "db-3", "3306", "maintenance").
+				AddRow("SHUNNED", "30", "db-4", "3306", nil).
+				AddRow("ONLINE", "20", "db-1", "3306", nil).
+				AddRow("OFFLINE_SOFT", "10", "db-2", "3306", nil).
+				AddRow("ONLINE", "20", "db-2", "3306", nil))
+			mock.ExpectQuery(sanitizeQuery(m.disk)).WillReturnRows(sqlmock.NewRows(servers).
+				AddRow("10", "db-1", "3306", "ONLINE", nil).
+				AddRow("10", "db-2", "3306", "ONLINE", nil).
+				AddRow("30", "db-3", "3306", "ONLINE", nil).
+				AddRow("30", "db-4", "3306", "ONLINE", nil))
+		case "mysql_users":
+			// runtime passwords are hashed, frontend and backend are split
+			mock.ExpectQuery(sanitizeQuery(m.memory)).WillReturnRows(sqlmock.NewRows(users).
+				AddRow("app", "secret", "1", "1"))
+			mock.ExpectQuery(sanitizeQuery(m.runtime)).WillReturnRows(sqlmock.NewRows(users).
+				AddRow("app", "*14E65567ABDB5135D0CFD9A70B3032C179A49EE7", "1", "0").
+				AddRow("app", "*14E65567ABDB5135D0CFD9A70B3032C179A49EE7", "0", "1"))
+			mock.ExpectQuery(sanitizeQuery(m.disk)).WillReturnRows(sqlmock.NewRows(users).
+				AddRow("app", "secret", "1", "1"))
+		case "mysql_variables":
+			mock.ExpectQuery(sanitizeQuery(m.memory)).WillReturnRows(sqlmock.NewRows(variables).
+				AddRow("mysql-max_connections", "4096"))
+			mock.ExpectQuery(sanitizeQuery(m.runtime)).WillReturnRows(sqlmock.NewRows(variables).
+				AddRow("mysql-max_connections", "2048"))
+			mock.ExpectQuery(sanitizeQuery(m.disk)).WillReturnRows(sqlmock.NewRows(variables).
+				AddRow("mysql-max_connections", "4096"))
+		default:
+			for _, query := range []string{m.memory, m.runtime, m.disk} {
+				mock.ExpectQuery(sanitizeQuery(query)).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+			}
+		}
+	}
+	mock.ExpectQuery(sanitizeQuery(configChecksumsQuery)).WillReturnRows(sqlmock.NewRows([]string{"name", "version", "epoch"}).
+		AddRow("mysql_servers", 3, 1700000000))
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		err = scrapeConfigDrift(context.Background(), db, ch)
+		close(ch)
+	}()
+
+	var results []metricResult
+	for m := range ch {
+		results = append(results, *readMetric(m))
+	}
+	require.NoError(t, err)
+
+	module := func(name string) prometheus.Labels { return prometheus.Labels{"module": name} }
+	assert.Equal(t, []metricResult{
+		{"proxysql_config_pending_runtime_load", module("mysql_servers"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("mysql_servers"), 1, dto.MetricType_GAUGE},
+		{"proxysql_config_pending_runtime_load", module("mysql_query_rules"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("mysql_query_rules"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_pending_runtime_load", module("mysql_users"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("mysql_users"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_pending_runtime_load", module("proxysql_servers"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("proxysql_servers"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_pending_runtime_load", module("mysql_variables"), 1, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("mysql_variables"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_pending_runtime_load", module("admin_variables"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_unsaved_to_disk", module("admin_variables"), 0, dto.MetricType_GAUGE},
+		{"proxysql_config_checksum_version", module("mysql_servers"), 3, dto.MetricType_GAUGE},
+		{"proxysql_config_checksum_epoch", module("mysql_servers"), 1700000000, dto.MetricType_GAUGE},
+	}, results)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRuntimeMySQLServersRow(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	tables := sqlmock.NewRows([]string{"tables"})
+	for _, q := range managedHostgroupsQueries {
+		tables.AddRow(q.table)
+	}
+	mock.ExpectQuery("SHOW TABLES FROM main").WillReturnRows(tables)
+	for _, q := range managedHostgroupsQueries {
+		mock.ExpectQuery(sanitizeQuery(q.query)).WillReturnRows(sqlmock.NewRows([]string{"writer_hostgroup", "reader_hostgroup"}).
+			AddRow("10", "20"))
+	}
+	f, err := runtimeMySQLServersRow(context.Background(), db)
+	require.NoError(t, err)
+	require.NoError(t, mock.ExpectationsWereMet())
+
+	// other values of managed servers are compared
+	row := map[string]string{"hostgroup_id": "20", "hostname": "db-1", "port": "3307", "status": "OFFLINE_SOFT"}
+	f(row)
+	assert.Equal(t, map[string]string{"hostname": "db-1", "port": "3307"}, row)
+
+	row = map[string]string{"hostgroup_id": "30", "hostname": "db-2", "status": "SHUNNED_REPLICATION_LAG"}
+	f(row)
+	assert.Equal(t, map[string]string{"hostgroup_id": "30", "hostname": "db-2", "status": "ONLINE"}, row)
+	row = map[string]string{"hostgroup_id": "30", "hostname": "db-2", "status": "OFFLINE_SOFT"}
+	f(row)
+	assert.Equal(t, map[string]string{"hostgroup_id": "30", "hostname": "db-2", "status": "OFFLINE_SOFT"}, row)
+}
+
+func TestConfigRowsDiffer(t *testing.T) {
+	assert.Equal(t, float64(0), configRowsDiffer(map[string]bool{"a": true}, map[string]bool{"a": true}))
+	assert.Equal(t, float64(1), configRowsDiffer(map[string]bool{"a": true}, map[string]bool{"b": true}))
+	assert.Equal(t, float64(1), configRowsDiffer(map[string]bool{"a": true}, map[string]bool{"a": true, "b": true}))
+}
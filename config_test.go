@@ -0,0 +1,143 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+auth_modules:
+  default:
+    username: stats
+    password: "p@ss:word"
+    params:
+      timeout: 5s
+`), 0o600))
+
+	cfg, err := loadConfig(path)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]AuthModule{
+		"default": {Username: "stats", Password: "p@ss:word", Params: map[string]string{"timeout": "5s"}},
+	}, cfg.AuthModules)
+	assert.Equal(t, "stats:p@ss:word@tcp(proxysql-1:6032)/?timeout=5s", cfg.AuthModules["default"].dsn("proxysql-1:6032"))
+
+	require.NoError(t, os.WriteFile(path, []byte("auth_modules:\n  default:\n    user: stats\n"), 0o600))
+	_, err = loadConfig(path)
+	assert.Error(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte("auth_modules:\n  default:\n    password: stats\n"), 0o600))
+	_, err = loadConfig(path)
+	assert.EqualError(t, err, `auth module "default": username is missing`)
+}
+
+func TestConfigApplyToFlags(t *testing.T) {
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	status := fs.Bool("collect.mysql_status", true, "")
+	digest := fs.Bool("collect.stats_mysql_query_digest", false, "")
+	options := collectorOptionFlags(fs, []Collector{
+		&collector{name: "stats_mysql_query_digest", options: []collectorOption{
+			{name: "limit", defValue: "100"},
+			{name: "schemanames"},
+		}},
+		&collector{name: "stats_mysql_users", options: []collectorOption{{name: "runtime", defValue: "false", isBool: true}}},
+		&collector{name: "stats_command_counter", options: []collectorOption{{name: "commands", defValue: "SELECT"}}},
+	})
+	limit := options["stats_mysql_query_digest"]["limit"]
+	schemas := options["stats_mysql_query_digest"]["schemanames"]
+	runtime := options["stats_mysql_users"]["runtime"]
+	commands := options["stats_command_counter"]["commands"]
+	require.NoError(t, fs.Parse([]string{"--collect.stats_mysql_query_digest.limit=10"}))
+	cmdline := map[string]bool{"collect.stats_mysql_query_digest.limit": true}
+
+	cfg := &Config{
+		Collectors: map[string]bool{"mysql_status": false, "stats_mysql_query_digest": true},
+		CollectorOptions: map[string]map[string]interface{}{
+			"stats_mysql_query_digest": {"limit": 50, "schemanames": []interface{}{"sbtest", "app"}},
+			"stats_mysql_users":        {"runtime": true},
+		},
+	}
+	require.NoError(t, cfg.applyToFlags(fs, cmdline))
+	assert.False(t, *status)
+	assert.True(t, *digest)
+	assert.Equal(t, "10", limit.String())
+	assert.Equal(t, "sbtest,app", schemas.String())
+	assert.Equal(t, "true", runtime.String())
+	assert.Equal(t, "SELECT", commands.String())
+
+	// values missing from configuration are reset to defaults
+	require.NoError(t, (&Config{}).applyToFlags(fs, cmdline))
+	assert.True(t, *status)
+	assert.False(t, *digest)
+	assert.Equal(t, "10", limit.String())
+	assert.Equal(t, "", schemas.String())
+	assert.Equal(t, "false", runtime.String())
+
+	cfg = &Config{Collectors: map[string]bool{"no_such_table": true}}
+	assert.EqualError(t, cfg.applyToFlags(fs, cmdline), `unknown collector "no_such_table"`)
+	cfg = &Config{Collectors: map[string]bool{"stats_mysql_query_digest.limit": true}}
+	assert.EqualError(t, cfg.applyToFlags(fs, cmdline), `unknown collector "stats_mysql_query_digest.limit"`)
+	cfg = &Config{Collectors: map[string]bool{"stats_mysql_users.runtime": true}}
+	assert.EqualError(t, cfg.applyToFlags(fs, cmdline), `unknown collector "stats_mysql_users.runtime"`)
+	cfg = &Config{CollectorOptions: map[string]map[string]interface{}{"stats_mysql_query_digest": {"order": "count_star"}}}
+	assert.EqualError(t, cfg.applyToFlags(fs, cmdline), `unknown collector option "stats_mysql_query_digest.order"`)
+	cfg = &Config{CollectorOptions: map[string]map[string]interface{}{"stats_mysql_users": {"runtime": "maybe"}}}
+	assert.Error(t, cfg.applyToFlags(fs, cmdline))
+}
+
+func TestProxySQLConfigDSN(t *testing.T) {
+	dsn, err := ProxySQLConfig{}.dsn()
+	require.NoError(t, err)
+	assert.Equal(t, "", dsn)
+
+	dsn, err = ProxySQLConfig{DSN: "stats:stats@tcp(localhost:6032)/"}.dsn()
+	require.NoError(t, err)
+	assert.Equal(t, "stats:stats@tcp(localhost:6032)/", dsn)
+
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0o600))
+	dsn, err = ProxySQLConfig{Host: "proxysql-1", Port: 6032, User: "admin", PasswordFile: passwordFile}.dsn()
+	require.NoError(t, err)
+	assert.Equal(t, "admin@tcp(proxysql-1:6032)/", dsn)
+
+	dsn, err = ProxySQLConfig{Address: "proxysql-1:6032", User: "admin"}.dsn()
+	require.NoError(t, err)
+	assert.Equal(t, "admin@tcp(proxysql-1:6032)/", dsn)
+
+	_, err = ProxySQLConfig{Host: "proxysql-1", PasswordFile: filepath.Join(t.TempDir(), "missing")}.dsn()
+	assert.Error(t, err)
+}
+
+func TestReadPasswordFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(path, []byte(" secret \r\n"), 0o600))
+	password, err := readPasswordFile(path)
+	require.NoError(t, err)
+	assert.Equal(t, " secret ", password)
+}
+
+func TestRedactDSN(t *testing.T) {
+	assert.Equal(t, "admin:xxxxx@tcp(127.0.0.1:6032)/?timeout=5s", redactDSN("admin:secret@tcp(127.0.0.1:6032)/?timeout=5s"))
+	assert.Equal(t, "stats@tcp(127.0.0.1:6032)/", redactDSN("stats@tcp(127.0.0.1:6032)/"))
+	assert.Equal(t, "<invalid DSN>", redactDSN("admin:secret@tcp(127.0.0.1:6032)"))
+}
@@ -15,12 +15,18 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-sql-driver/mysql"
@@ -29,45 +35,56 @@ import (
 
 const namespace = "proxysql"
 
+// Connection pool settings. Connections are kept open between scrapes
+// to avoid a new TCP and authentication handshake with ProxySQL admin interface on every scrape.
+const (
+	dbMaxOpenConns    = 3
+	dbMaxIdleConns    = 3
+	dbConnMaxLifetime = time.Hour
+)
+
+// ExporterOptions configures which collectors Exporter runs and how.
+type ExporterOptions struct {
+	// PasswordFile, if not empty, contains password which overrides the one in DSN.
+	// It is re-read for every new connection, so rotated passwords are picked up after connection failure.
+	PasswordFile string
+
+	// Collectors contains names of enabled collectors, see collectors.
+	Collectors map[string]bool
+
+	// CollectorOptions contains option values of configurable collectors by collector and option name;
+	// missing values are set to defaults, see configureCollector.
+	CollectorOptions map[string]optionValues
+}
+
 // Exporter collects ProxySQL metrics.
 // It implements prometheus.Collector interface.
 type Exporter struct {
-	dsn                              string
-	scrapeMySQLGlobal                bool
-	scrapeMySQLConnectionPool        bool
-	scrapeMySQLConnectionList        bool
-	scrapeDetailedMySQLProcessList   bool
-	scrapeMySQLRuntimeServers        bool
-	scrapeMemoryMetrics              bool
-	scrapeMySQLCommandCounterMetrics bool
-	scrapesTotal                     prometheus.Counter
-	scrapeErrorsTotal                *prometheus.CounterVec
-	lastScrapeError                  prometheus.Gauge
-	lastScrapeDurationSeconds        prometheus.Gauge
-	proxysqlUp                       prometheus.Gauge
+	// settingsMtx protects dsn and opts which can be changed by Update.
+	settingsMtx               sync.RWMutex
+	dsn                       string
+	opts                      ExporterOptions
+	scrapesTotal              prometheus.Counter
+	scrapeErrorsTotal         *prometheus.CounterVec
+	lastScrapeError           prometheus.Gauge
+	lastScrapeDurationSeconds prometheus.Gauge
+	proxysqlUp                prometheus.Gauge
+	reconnectsTotal           prometheus.Counter
+
+	dbMtx          sync.Mutex
+	dbPool         *sql.DB
+	dbDSN          string
+	dbPasswordFile string
+	dbFailed       bool
+	dbCaps         *capabilities // detected for dbPool, nil if not yet
 }
 
 // NewExporter returns a new ProxySQL exporter for the provided DSN.
-// It scrapes stats_mysql_global, stats_mysql_connection_pool and stats_mysql_processlist if corresponding parameters are true.
-func NewExporter(
-	dsn string,
-	scrapeMySQLGlobal bool,
-	scrapeMySQLConnectionPool bool,
-	scrapeMySQLConnectionList bool,
-	scrapeDetailedMySQLProcessList bool,
-	scrapeMySQLRuntimeServers bool,
-	scrapeMemoryMetrics bool,
-	scrapeMySQLCommandCounterMetrics bool,
-) *Exporter {
+// It runs collectors enabled in opts.
+func NewExporter(dsn string, opts ExporterOptions) *Exporter {
 	return &Exporter{
-		dsn:                              dsn,
-		scrapeMySQLGlobal:                scrapeMySQLGlobal,
-		scrapeMySQLConnectionPool:        scrapeMySQLConnectionPool,
-		scrapeMySQLConnectionList:        scrapeMySQLConnectionList,
-		scrapeDetailedMySQLProcessList:   scrapeDetailedMySQLProcessList,
-		scrapeMySQLRuntimeServers:        scrapeMySQLRuntimeServers,
-		scrapeMemoryMetrics:              scrapeMemoryMetrics,
-		scrapeMySQLCommandCounterMetrics: scrapeMySQLCommandCounterMetrics,
+		dsn:  dsn,
+		opts: opts,
 
 		scrapesTotal: prometheus.NewCounter(prometheus.CounterOpts{
 			Namespace: namespace,
@@ -98,6 +115,12 @@ func NewExporter(
 			Name:      "up",
 			Help:      "Whether ProxySQL is up.",
 		}),
+		reconnectsTotal: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "reconnects_total",
+			Help:      "Total number of times the connection pool to ProxySQL was re-created after a failure.",
+		}),
 	}
 }
 
@@ -105,53 +128,201 @@ func NewExporter(
 // to the provided channel and returns once the last descriptor has been sent.
 // Part of prometheus.Collector interface.
 func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
-	// We cannot know in advance what metrics the exporter will generate
-	// from ProxySQL. So we use the poor man's describe method: Run a collect
-	// and send the descriptors of all the collected metrics. The problem
-	// here is that we need to connect to the ProxySQL. If it is currently
-	// unavailable, the descriptors will be incomplete. Since this is a
-	// stand-alone exporter and not used as a library within other code
-	// implementing additional metrics, the worst that can happen is that we
-	// don't detect inconsistent metrics created by this exporter
-	// itself. Also, a change in the monitored ProxySQL instance may change the
-	// exported metrics during the runtime of the exporter.
-
-	metricCh := make(chan prometheus.Metric)
-	doneCh := make(chan struct{})
-
-	go func() {
-		for m := range metricCh {
-			ch <- m.Desc()
-		}
-		close(doneCh)
-	}()
+	// Descriptors of known metrics are created once at startup, so describing does not connect to ProxySQL.
+	// Undocumented metrics (unknown variables and columns) can't be described in advance;
+	// they are collected unchecked, see metricDescs.
+	for _, desc := range describedDescs {
+		ch <- desc
+	}
 
-	e.Collect(metricCh)
-	close(metricCh)
-	<-doneCh
+	e.scrapesTotal.Describe(ch)
+	e.scrapeErrorsTotal.Describe(ch)
+	e.lastScrapeError.Describe(ch)
+	e.lastScrapeDurationSeconds.Describe(ch)
+	e.proxysqlUp.Describe(ch)
+	e.reconnectsTotal.Describe(ch)
 }
 
 // Collect is called by the Prometheus registry when collecting metrics.
 // Part of prometheus.Collector interface.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.scrape(ch)
+	e.collect(context.Background(), ch)
+}
+
+// WithContext returns prometheus.Collector which collects metrics from ProxySQL
+// canceling outstanding queries when ctx is done. It is intended to be registered
+// in a per-request registry, so it is unchecked and does not describe any metrics.
+func (e *Exporter) WithContext(ctx context.Context) prometheus.Collector {
+	return &contextCollector{e: e, ctx: ctx}
+}
+
+type contextCollector struct {
+	e   *Exporter
+	ctx context.Context //nolint:containedctx
+}
+
+// Describe implements prometheus.Collector.
+func (c *contextCollector) Describe(chan<- *prometheus.Desc) {}
+
+// Collect implements prometheus.Collector.
+func (c *contextCollector) Collect(ch chan<- prometheus.Metric) {
+	c.e.collect(c.ctx, ch)
+}
+
+func (e *Exporter) collect(ctx context.Context, ch chan<- prometheus.Metric) {
+	e.scrape(ctx, ch)
 
 	e.scrapesTotal.Collect(ch)
 	e.scrapeErrorsTotal.Collect(ch)
 	e.lastScrapeError.Collect(ch)
 	e.lastScrapeDurationSeconds.Collect(ch)
 	e.proxysqlUp.Collect(ch)
+	e.reconnectsTotal.Collect(ch)
+}
+
+// Update atomically replaces DSN and options used by the next scrape.
+// Exporter's own metrics (like scrapes_total) are preserved.
+// The connection pool is re-created if DSN is changed.
+func (e *Exporter) Update(dsn string, opts ExporterOptions) {
+	e.settingsMtx.Lock()
+	defer e.settingsMtx.Unlock()
+
+	e.dsn = dsn
+	e.opts = opts
+}
+
+// settings returns current DSN and options.
+func (e *Exporter) settings() (string, ExporterOptions) {
+	e.settingsMtx.RLock()
+	defer e.settingsMtx.RUnlock()
+
+	return e.dsn, e.opts
+}
+
+// db returns a long-lived connection pool to ProxySQL, opening it if needed.
+// If ProxySQL can't be pinged, the pool is closed so that the next call re-creates it.
+func (e *Exporter) db(ctx context.Context) (*sql.DB, error) {
+	e.dbMtx.Lock()
+	defer e.dbMtx.Unlock()
+
+	dsn, opts := e.settings()
+	if e.dbPool != nil && (e.dbDSN != dsn || e.dbPasswordFile != opts.PasswordFile) {
+		e.dbPool.Close() //nolint:errcheck
+		e.dbPool = nil
+		e.dbCaps = nil
+	}
+
+	if e.dbPool == nil {
+		db, err := openDB(dsn, opts.PasswordFile)
+		if err != nil {
+			e.dbFailed = true
+			return nil, err
+		}
+		db.SetMaxOpenConns(dbMaxOpenConns)
+		db.SetMaxIdleConns(dbMaxIdleConns)
+		db.SetConnMaxLifetime(dbConnMaxLifetime)
+		if e.dbFailed {
+			e.reconnectsTotal.Inc()
+		}
+		e.dbPool = db
+		e.dbDSN = dsn
+		e.dbPasswordFile = opts.PasswordFile
+	}
+
+	if err := e.dbPool.PingContext(ctx); err != nil {
+		e.dbPool.Close() //nolint:errcheck
+		e.dbPool = nil
+		e.dbCaps = nil
+		e.dbFailed = true
+		return nil, err
+	}
+	e.dbFailed = false
+	return e.dbPool, nil
+}
+
+// capabilities returns capabilities of ProxySQL detected once for the connection pool.
+// They are re-detected when connections are recycled (after dbConnMaxLifetime), so ProxySQL upgrades are noticed.
+// It returns nil if detection failed; it is retried on the next call.
+func (e *Exporter) capabilities(ctx context.Context, db *sql.DB) *capabilities {
+	e.dbMtx.Lock()
+	defer e.dbMtx.Unlock()
+
+	if e.dbCaps != nil && time.Since(e.dbCaps.detectedAt) < dbConnMaxLifetime {
+		return e.dbCaps
+	}
+
+	caps, err := detectCapabilities(ctx, db)
+	if err != nil {
+		logger.Warn("Failed to detect ProxySQL version and tables, running all collectors", "error", err)
+		return nil
+	}
+	logger.Debug("Detected ProxySQL capabilities", "version", caps.version, "tables", len(caps.tables))
+	e.dbCaps = caps
+	return caps
+}
+
+// openDB returns connection pool for DSN. If passwordFile is not empty,
+// the password is read from it for every new connection.
+func openDB(dsn, passwordFile string) (*sql.DB, error) {
+	if passwordFile == "" {
+		return sql.Open("mysql", dsn)
+	}
+
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Apply(mysql.BeforeConnect(func(_ context.Context, cfg *mysql.Config) error {
+		password, err := readPasswordFile(passwordFile)
+		if err != nil {
+			return err
+		}
+		cfg.Passwd = password
+		return nil
+	}))
+	if err != nil {
+		return nil, err
+	}
+	connector, err := mysql.NewConnector(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return sql.OpenDB(connector), nil
+}
+
+// Close closes the connection pool to ProxySQL.
+func (e *Exporter) Close() error {
+	e.dbMtx.Lock()
+	defer e.dbMtx.Unlock()
+
+	if e.dbPool == nil {
+		return nil
+	}
+	err := e.dbPool.Close()
+	e.dbPool = nil
+	e.dbCaps = nil
+	return err
 }
 
-func (e *Exporter) db() (*sql.DB, error) {
-	db, err := sql.Open("mysql", e.dsn)
-	if err == nil {
-		err = db.Ping()
+// scrapers returns enabled collectors configured with exporter options.
+func (e *Exporter) scrapers() []Collector {
+	_, opts := e.settings()
+	var res []Collector
+	for _, c := range collectors {
+		if !opts.Collectors[c.Name()] {
+			continue
+		}
+		c, err := configureCollector(c, opts)
+		if err != nil {
+			logger.Error("Invalid collector options", "error", err)
+			continue
+		}
+		res = append(res, c)
 	}
-	return db, err
+	return res
 }
 
-func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
+func (e *Exporter) scrape(ctx context.Context, ch chan<- prometheus.Metric) {
 	e.scrapesTotal.Inc()
 	var err error
 	defer func(begun time.Time) {
@@ -163,10 +334,7 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 		}
 	}(time.Now())
 
-	db, err := e.db()
-	if db != nil {
-		defer db.Close()
-	}
+	db, err := e.db(ctx)
 	if err != nil {
 		logger.Error("Error opening connection to ProxySQL", "error", err)
 		e.proxysqlUp.Set(0)
@@ -174,60 +342,104 @@ func (e *Exporter) scrape(ch chan<- prometheus.Metric) {
 	}
 	e.proxysqlUp.Set(1)
 
-	if e.scrapeMySQLGlobal {
-		if err = scrapeMySQLGlobal(db, ch); err != nil {
-			logger.Error("Error scraping for collect.mysql_status:", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_status").Inc()
-		}
-	}
-	if e.scrapeMySQLConnectionPool {
-		if err = scrapeMySQLConnectionPool(db, ch); err != nil {
-			logger.Error("Error scraping for collect.mysql_connection_pool:", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_connection_pool").Inc()
-		}
-	}
-	if e.scrapeMySQLConnectionList {
-		if err = scrapeMySQLConnectionList(db, ch); err != nil {
-			logger.Error("Error scraping for collect.mysql_connection_list:", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.mysql_connection_list").Inc()
-		}
-	}
-	if e.scrapeDetailedMySQLProcessList {
-		if err = scrapeDetailedMySQLConnectionList(db, ch); err != nil {
-			logger.Error("Error scraping for collect.stats_mysql_processlist", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.stats_mysql_processlist").Inc()
+	scrapers := supportedScrapers(ch, e.capabilities(ctx, db), e.scrapers())
+	err = e.runScrapers(ctx, db, ch, scrapers)
+}
+
+// supportedScrapers returns collectors supported by ProxySQL and sends collector_supported metric for every collector.
+// Unsupported collectors are skipped instead of failing on every scrape.
+func supportedScrapers(ch chan<- prometheus.Metric, caps *capabilities, scrapers []Collector) []Collector {
+	var res []Collector
+	for _, c := range scrapers {
+		var supported float64
+		if caps.supports(c) {
+			supported = 1
+			res = append(res, c)
 		}
+		ch <- prometheus.MustNewConstMetric(collectorSupportedDesc, prometheus.GaugeValue, supported, collectorLabel(c))
 	}
-	if e.scrapeMySQLRuntimeServers {
-		if err = scrapeMySQLRuntimeServers(db, ch); err != nil {
-			// Permission errors (missing admin rights) for runtime metrics are logged only at debug level.
-			// If permissions are insufficient, runtime metrics collection is skipped and no error is reported.
-			var mysqlErr *mysql.MySQLError
-			if errors.As(err, &mysqlErr) && mysqlErr.Number == 1045 {
-				logger.Debug("Error scraping for collect.runtime_mysql_servers", "error", err)
-			} else {
-				logger.Error("Error scraping for collect.runtime_mysql_servers", "error", err)
-				e.scrapeErrorsTotal.WithLabelValues("collect.runtime_mysql_servers").Inc()
+	return res
+}
+
+// runScrapers runs collectors concurrently over the shared connection pool.
+// It returns an error if any of them failed.
+func (e *Exporter) runScrapers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, scrapers []Collector) error {
+	var wg sync.WaitGroup
+	var failed atomic.Bool
+	for _, c := range scrapers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if !e.runScraper(ctx, db, ch, c) {
+				failed.Store(true)
 			}
-		}
+		}()
 	}
-	if e.scrapeMemoryMetrics {
-		if err = scrapeMemoryMetrics(db, ch); err != nil {
-			logger.Error("Error scraping for collect.stats_memory_metrics", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.stats_memory_metrics").Inc()
-		}
+	wg.Wait()
+
+	if failed.Load() {
+		return errors.New("one or more collectors failed")
 	}
-	if e.scrapeMySQLCommandCounterMetrics {
-		if err = scrapeMySQLCommandCounterMetrics(db, ch); err != nil {
-			logger.Error("Error scraping for collect.stats_command_counter_metrics", "error", err)
-			e.scrapeErrorsTotal.WithLabelValues("collect.stats_command_counter_metrics").Inc()
+	return nil
+}
+
+// runScraper runs a single collector and sends its duration, success and timeout metrics.
+// Metrics sent by the collector before ctx is done are kept.
+func (e *Exporter) runScraper(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, c Collector) bool {
+	name := collectorLabel(c)
+	begun := time.Now()
+	err := c.Scrape(ctx, db, ch)
+	duration := time.Since(begun).Seconds()
+
+	success := true
+	var timeout float64
+	if err != nil {
+		if ctx.Err() != nil {
+			timeout = 1
+		}
+		if i, ok := c.(permissionErrorIgnorer); ok && i.ignorePermissionError() && isPermissionError(err) {
+			logger.Debug("Error scraping for "+name, "error", err)
+		} else {
+			logger.Error("Error scraping for "+name, "error", err)
+			e.scrapeErrorsTotal.WithLabelValues(name).Inc()
+			success = false
 		}
 	}
 
-	if err = scrapeProxySQLInfo(db, ch); err != nil {
-		logger.Error("Error scraping for collect.proxysql_info", "error", err)
-		e.scrapeErrorsTotal.WithLabelValues("collect.proxysql_info").Inc()
+	ch <- prometheus.MustNewConstMetric(collectorDurationDesc, prometheus.GaugeValue, duration, name)
+	var successValue float64
+	if success {
+		successValue = 1
 	}
+	ch <- prometheus.MustNewConstMetric(collectorSuccessDesc, prometheus.GaugeValue, successValue, name)
+	ch <- prometheus.MustNewConstMetric(collectorTimeoutDesc, prometheus.GaugeValue, timeout, name)
+	return success
+}
+
+var (
+	collectorDurationDesc = newDesc("exporter", "collector_duration_seconds",
+		"Duration of the last scrape of the collector.",
+		[]string{"collector"},
+	)
+	collectorSuccessDesc = newDesc("exporter", "collector_success",
+		"Whether the last scrape of the collector succeeded (1 for success, 0 for error).",
+		[]string{"collector"},
+	)
+	collectorSupportedDesc = newDesc("exporter", "collector_supported",
+		"Whether the collector is supported by ProxySQL version and tables (1 for supported, 0 for skipped).",
+		[]string{"collector"},
+	)
+	collectorTimeoutDesc = newDesc("exporter", "collector_timeout",
+		"Whether the last scrape of the collector was canceled by the scrape timeout (1 for timeout, 0 otherwise).",
+		[]string{"collector"},
+	)
+)
+
+// isPermissionError returns true if err is ProxySQL's "access denied" error
+// returned for admin-only tables queried with insufficient (e.g. stats) credentials.
+func isPermissionError(err error) bool {
+	var mysqlErr *mysql.MySQLError
+	return errors.As(err, &mysqlErr) && mysqlErr.Number == 1045
 }
 
 // metric contains information about Prometheus metric.
@@ -237,6 +449,72 @@ type metric struct {
 	help      string
 }
 
+// describedDescs contains descriptors of all known metrics. They are created once at startup
+// and sent by Exporter.Describe, so registration of the exporter does not connect to ProxySQL.
+var describedDescs []*prometheus.Desc
+
+// newDesc returns a new descriptor of a known metric and adds it to describedDescs.
+func newDesc(subsystem, name, help string, labels []string) *prometheus.Desc {
+	desc := prometheus.NewDesc(prometheus.BuildFQName(namespace, subsystem, name), help, labels, nil)
+	describedDescs = append(describedDescs, desc)
+	return desc
+}
+
+// metricDescs contains descriptors of metrics created from variables or columns of a single table.
+// Descriptors of known metrics are created once. Descriptors of undocumented metrics (unknown variables
+// or columns added in newer ProxySQL versions) are created on first use and cached; they can't be described
+// in advance, so such metrics are collected unchecked.
+type metricDescs struct {
+	subsystem        string
+	labels           []string
+	undocumentedHelp string
+	metrics          map[string]*metric
+	descs            map[string]*prometheus.Desc
+
+	mtx          sync.Mutex
+	undocumented map[string]*prometheus.Desc
+}
+
+// newMetricDescs returns descriptors of metrics with the given subsystem and labels.
+// Keys of metrics are variable or column names in lowercase.
+func newMetricDescs(subsystem string, labels []string, metrics map[string]*metric, undocumentedHelp string) *metricDescs {
+	d := &metricDescs{
+		subsystem:        subsystem,
+		labels:           labels,
+		undocumentedHelp: undocumentedHelp,
+		metrics:          metrics,
+		descs:            make(map[string]*prometheus.Desc, len(metrics)),
+		undocumented:     make(map[string]*prometheus.Desc),
+	}
+	for key, m := range metrics {
+		d.descs[key] = newDesc(subsystem, m.name, m.help, labels)
+	}
+	return d
+}
+
+// get returns known metric and its descriptor for the key, or nils if metric is unknown.
+func (d *metricDescs) get(key string) (*metric, *prometheus.Desc) {
+	return d.metrics[key], d.descs[key]
+}
+
+// getOrUndocumented returns value type and descriptor of the metric for the key.
+// Unknown metrics are untyped and named by the given name.
+func (d *metricDescs) getOrUndocumented(key, name string) (prometheus.ValueType, *prometheus.Desc) {
+	if m, desc := d.get(key); m != nil {
+		return m.valueType, desc
+	}
+
+	d.mtx.Lock()
+	defer d.mtx.Unlock()
+
+	desc := d.undocumented[name]
+	if desc == nil {
+		desc = prometheus.NewDesc(prometheus.BuildFQName(namespace, d.subsystem, name), d.undocumentedHelp, d.labels, nil)
+		d.undocumented[name] = desc
+	}
+	return prometheus.UntypedValue, desc
+}
+
 const mySQLGlobalQuery = "SELECT Variable_Name, Variable_Value FROM stats_mysql_global"
 
 // https://github.com/sysown/proxysql/blob/master/doc/admin_tables.md#stats_mysql_global
@@ -260,9 +538,16 @@ var mySQLGlobalMetrics = map[string]*metric{
 		"Total number of queries that ran for longer than the threshold in milliseconds defined in global variable mysql-long_query_time."},
 }
 
+var mySQLGlobalDescs = newMetricDescs("mysql_status", nil, mySQLGlobalMetrics, "Undocumented stats_mysql_global metric.")
+
 // scrapeMySQLGlobal collects metrics from `stats_mysql_global`.
-func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mySQLGlobalQuery)
+func scrapeMySQLGlobal(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeGlobal(ctx, db, ch, mySQLGlobalQuery, mySQLGlobalDescs)
+}
+
+// scrapeGlobal collects metrics from variables returned by query (stats_mysql_global or stats_pgsql_global).
+func scrapeGlobal(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -280,22 +565,8 @@ func scrapeMySQLGlobal(db *sql.DB, ch chan<- prometheus.Metric) error {
 		}
 
 		name = strings.ToLower(name)
-		m := mySQLGlobalMetrics[name]
-		if m == nil {
-			m = &metric{
-				name:      name,
-				valueType: prometheus.UntypedValue,
-				help:      "Undocumented stats_mysql_global metric.",
-			}
-		}
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "mysql_status", m.name),
-				m.help,
-				nil, nil,
-			),
-			m.valueType, value,
-		)
+		valueType, desc := descs.getOrUndocumented(name, name)
+		ch <- prometheus.MustNewConstMetric(desc, valueType, value)
 	}
 	return rows.Err()
 }
@@ -331,9 +602,18 @@ var mySQLconnectionPoolMetrics = map[string]*metric{
 		"The currently ping time in microseconds, as reported from Monitor."},
 }
 
+var mySQLconnectionPoolDescs = newMetricDescs("connection_pool", []string{"hostgroup", "endpoint"}, mySQLconnectionPoolMetrics,
+	"Undocumented stats_mysql_connection_pool metric.")
+
 // scrapeMySQLConnectionPool collects metrics from `stats_mysql_connection_pool`.
-func scrapeMySQLConnectionPool(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mySQLconnectionPoolQuery)
+func scrapeMySQLConnectionPool(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeConnectionPool(ctx, db, ch, mySQLconnectionPoolQuery, mySQLconnectionPoolDescs)
+}
+
+// scrapeConnectionPool collects metrics from connection pool returned by query
+// (stats_mysql_connection_pool or stats_pgsql_connection_pool).
+func scrapeConnectionPool(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -389,23 +669,8 @@ func scrapeMySQLConnectionPool(db *sql.DB, ch chan<- prometheus.Metric) error {
 				}
 			}
 
-			m := mySQLconnectionPoolMetrics[column]
-			if m == nil {
-				m = &metric{
-					name:      column,
-					valueType: prometheus.UntypedValue,
-					help:      "Undocumented stats_mysql_connection_pool metric.",
-				}
-			}
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					prometheus.BuildFQName(namespace, "connection_pool", m.name),
-					m.help,
-					[]string{"hostgroup", "endpoint"}, nil,
-				),
-				m.valueType, value,
-				hostgroup, srvHost+":"+srvPort,
-			)
+			valueType, desc := descs.getOrUndocumented(column, column)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, hostgroup, srvHost+":"+srvPort)
 		}
 	}
 	return rows.Err()
@@ -418,9 +683,12 @@ var mySQLconnectionListMetrics = map[string]*metric{
 		"Total number of frontend connections"},
 }
 
+var mySQLconnectionListDescs = newMetricDescs("processlist", []string{"client_host"}, mySQLconnectionListMetrics,
+	"Undocumented stats_mysql_processlist metric.")
+
 // scrapeMySQLConnectionList collects connection list from `stats_mysql_processlist`.
-func scrapeMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mySQLConnectionListQuery)
+func scrapeMySQLConnectionList(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, mySQLConnectionListQuery)
 	if err != nil {
 		return err
 	}
@@ -444,24 +712,8 @@ func scrapeMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
 
 		column := strings.ToLower(columns[0])
 
-		m := mySQLconnectionListMetrics[column]
-		if m == nil {
-			m = &metric{
-				name:      "client_connection_list",
-				valueType: prometheus.UntypedValue,
-				help:      "Undocumented stats_mysql_processlist metric.",
-			}
-		}
-
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "processlist", m.name),
-				m.help,
-				[]string{"client_host"}, nil,
-			),
-			m.valueType, connNum,
-			cliHost,
-		)
+		valueType, desc := mySQLconnectionListDescs.getOrUndocumented(column, "client_connection_list")
+		ch <- prometheus.MustNewConstMetric(desc, valueType, connNum, cliHost)
 	}
 	return rows.Err()
 }
@@ -472,13 +724,22 @@ var detailedMySQLProcessListMetrics = map[string]*metric{
 	"detailed_connection_count": {name: "detailed_client_connection_count", valueType: prometheus.GaugeValue, help: "Number of client connections per user, db, host and hostgroup."},
 }
 
+var detailedMySQLProcessListDescs = newMetricDescs("processlist", []string{"user", "db", "client_host", "hostgroup"},
+	detailedMySQLProcessListMetrics, "")
+
 type processListResult struct {
 	user, db, clientHost, hostGroup string
 	count                           float64
 }
 
-func scrapeDetailedMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(detailedMySQLProcessListQuery)
+func scrapeDetailedMySQLConnectionList(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeDetailedConnectionList(ctx, db, ch, detailedMySQLProcessListQuery, detailedMySQLProcessListDescs)
+}
+
+// scrapeDetailedConnectionList collects client connection counts returned by query
+// (from stats_mysql_processlist or stats_pgsql_processlist).
+func scrapeDetailedConnectionList(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -492,15 +753,10 @@ func scrapeDetailedMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric)
 			return err
 		}
 
-		m := detailedMySQLProcessListMetrics["detailed_connection_count"]
+		m, desc := descs.get("detailed_connection_count")
 
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "processlist", m.name),
-				m.help,
-				[]string{"user", "db", "client_host", "hostgroup"},
-				nil,
-			),
+			desc,
 			m.valueType,
 			res.count,
 			res.user, res.db, res.clientHost, res.hostGroup,
@@ -510,88 +766,284 @@ func scrapeDetailedMySQLConnectionList(db *sql.DB, ch chan<- prometheus.Metric)
 	return rows.Err()
 }
 
-const mysqlCommandCounterQuery = `
-    SELECT
-        Command, Total_Time_us, Total_cnt, cnt_100us, cnt_500us, cnt_1ms, cnt_5ms, cnt_10ms, cnt_50ms,
-        cnt_100ms, cnt_500ms, cnt_1s, cnt_5s, cnt_10s, cnt_INFs
-    FROM
-        stats_mysql_commands_counters
-    WHERE Command IN (
-        'CREATE_TEMPORARY',
-        'DELETE',
-        'INSERT',
-        'LOCK_TABLES',
-        'SELECT',
-        'SELECT_FOR_UPDATE',
-        'UPDATE'
-    )
-`
+const mySQLSessionsQuery = "SELECT hostgroup, srv_host, srv_port, command, time_ms FROM stats_mysql_processlist"
+
+var (
+	mySQLSessionsDesc = newDesc("processlist", "sessions",
+		"Number of client sessions per command, hostgroup and backend endpoint (empty if session has no backend connection).",
+		[]string{"command", "hostgroup", "endpoint"})
+	mySQLSessionsActiveTimeDesc = newDesc("processlist", "active_session_time_milliseconds",
+		"Histogram over time in ms active (not in Sleep command) client sessions spent in their current command.",
+		[]string{"hostgroup"})
+	mySQLSessionsOldestQueryDesc = newDesc("processlist", "oldest_query_time_milliseconds",
+		"Time in ms of the longest running query, 0 if there are no running queries.",
+		[]string{"hostgroup"})
+)
+
+// mySQLSessionsActiveTimeBuckets are upper bounds in ms of active_session_time_milliseconds histogram buckets.
+var mySQLSessionsActiveTimeBuckets = []float64{10, 100, 1000, 10000, 60000, 300000, 900000, 3600000}
 
-type mysqlCommandCounterResult struct {
-	Command     string
-	TotalTimeUs float64
-	TotalCnt    uint64
-	cnt100us    uint64
-	cnt500us    uint64
-	cnt1ms      uint64
-	cnt5ms      uint64
-	cnt10ms     uint64
-	cnt50ms     uint64
-	cnt100ms    uint64
-	cnt500ms    uint64
-	cnt1s       uint64
-	cnt5s       uint64
-	cnt10s      uint64
-	cntINFs     uint64
+// sessionsHostgroup accumulates stats_mysql_processlist sessions of a single hostgroup.
+type sessionsHostgroup struct {
+	counts      map[[2]string]float64 // by command and endpoint
+	activeCount uint64
+	activeSum   float64
+	buckets     map[float64]uint64
+	oldestQuery float64
 }
 
-func scrapeMySQLCommandCounterMetrics(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mysqlCommandCounterQuery)
+// scrapeMySQLSessions collects session counts, active session times and oldest running query age
+// from `stats_mysql_processlist`.
+func scrapeMySQLSessions(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, mySQLSessionsQuery)
 	if err != nil {
 		return err
 	}
 	defer rows.Close()
 
+	hostgroups := make(map[string]*sessionsHostgroup)
 	for rows.Next() {
-		var res mysqlCommandCounterResult
+		var hostgroup, srvHost, srvPort, command sql.NullString
+		var timeMs sql.NullFloat64
+		if err = rows.Scan(&hostgroup, &srvHost, &srvPort, &command, &timeMs); err != nil {
+			return err
+		}
 
-		err := rows.Scan(
-			&res.Command, &res.TotalTimeUs, &res.TotalCnt, &res.cnt100us, &res.cnt500us, &res.cnt1ms, &res.cnt5ms, &res.cnt10ms,
-			&res.cnt50ms, &res.cnt100ms, &res.cnt500ms, &res.cnt1s, &res.cnt5s, &res.cnt10s, &res.cntINFs,
-		)
+		hg := hostgroups[hostgroup.String]
+		if hg == nil {
+			hg = &sessionsHostgroup{
+				counts:  make(map[[2]string]float64),
+				buckets: make(map[float64]uint64, len(mySQLSessionsActiveTimeBuckets)),
+			}
+			hostgroups[hostgroup.String] = hg
+		}
+
+		var endpoint string
+		if srvHost.String != "" {
+			endpoint = srvHost.String + ":" + srvPort.String
+		}
+		hg.counts[[2]string{command.String, endpoint}]++
+
+		if command.String == "Sleep" {
+			continue
+		}
+		hg.activeCount++
+		hg.activeSum += timeMs.Float64
+		for _, bound := range mySQLSessionsActiveTimeBuckets {
+			if timeMs.Float64 <= bound {
+				hg.buckets[bound]++
+			}
+		}
+		if command.String == "Query" && timeMs.Float64 > hg.oldestQuery {
+			hg.oldestQuery = timeMs.Float64
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// sort for stable output
+	names := make([]string, 0, len(hostgroups))
+	for name := range hostgroups {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+
+	for _, name := range names {
+		hg := hostgroups[name]
+
+		keys := make([][2]string, 0, len(hg.counts))
+		for key := range hg.counts {
+			keys = append(keys, key)
+		}
+		slices.SortFunc(keys, func(a, b [2]string) int { return slices.Compare(a[:], b[:]) })
+		for _, key := range keys {
+			ch <- prometheus.MustNewConstMetric(mySQLSessionsDesc, prometheus.GaugeValue, hg.counts[key], key[0], name, key[1])
+		}
+
+		ch <- prometheus.MustNewConstHistogram(mySQLSessionsActiveTimeDesc, hg.activeCount, hg.activeSum, hg.buckets, name)
+		ch <- prometheus.MustNewConstMetric(mySQLSessionsOldestQueryDesc, prometheus.GaugeValue, hg.oldestQuery, name)
+	}
+	return nil
+}
+
+const mysqlCommandCounterQuery = "SELECT * FROM stats_mysql_commands_counters"
+
+var mysqlCommandCounterDesc = newDesc("mysql_command_counter", "latency_milliseconds",
+	"histogram over a commands latency in ms",
+	[]string{"command"},
+)
+
+// commandCounterFilter selects commands collected from stats_mysql_commands_counters.
+// A nil filter selects all commands.
+type commandCounterFilter struct {
+	allow map[string]bool // if not empty, only these commands are collected
+	deny  map[string]bool
+	match *regexp.Regexp // if not nil, only matching commands are collected
+}
+
+// newCommandCounterFilter returns filter for comma-separated lists of allowed and denied commands
+// and regular expression. Allow list "all" or empty allows all commands.
+func newCommandCounterFilter(allow, deny, match string) (*commandCounterFilter, error) {
+	f := &commandCounterFilter{
+		allow: make(map[string]bool),
+		deny:  make(map[string]bool),
+	}
+	for _, c := range strings.Split(allow, ",") {
+		c = strings.ToUpper(strings.TrimSpace(c))
+		if c == "ALL" {
+			f.allow = make(map[string]bool)
+			break
+		}
+		if c != "" {
+			f.allow[c] = true
+		}
+	}
+	for _, c := range strings.Split(deny, ",") {
+		if c = strings.ToUpper(strings.TrimSpace(c)); c != "" {
+			f.deny[c] = true
+		}
+	}
+	if match != "" {
+		re, err := regexp.Compile(match)
 		if err != nil {
+			return nil, fmt.Errorf("invalid command regex %q: %w", match, err)
+		}
+		f.match = re
+	}
+	return f, nil
+}
+
+// selected returns true if command should be collected.
+func (f *commandCounterFilter) selected(command string) bool {
+	if f == nil {
+		return true
+	}
+	command = strings.ToUpper(command)
+	if len(f.allow) != 0 && !f.allow[command] {
+		return false
+	}
+	if f.deny[command] {
+		return false
+	}
+	return f.match == nil || f.match.MatchString(command)
+}
+
+var commandCounterBucketRE = regexp.MustCompile(`^cnt_(\d+)(us|ms|s)$`)
+
+// commandCounterBucket returns upper bound in milliseconds for stats_mysql_commands_counters
+// bucket column like cnt_100us, cnt_5ms, cnt_10s or cnt_INFs.
+func commandCounterBucket(column string) (float64, bool) {
+	column = strings.ToLower(column)
+	if column == "cnt_infs" {
+		return math.Inf(1), true
+	}
+	m := commandCounterBucketRE.FindStringSubmatch(column)
+	if m == nil {
+		return 0, false
+	}
+	bound, err := strconv.ParseFloat(m[1], 64)
+	if err != nil {
+		return 0, false
+	}
+	switch m[2] {
+	case "us":
+		bound /= 1000
+	case "s":
+		bound *= 1000
+	}
+	return bound, true
+}
+
+// scrapeMySQLCommandCounterMetrics collects histograms from `stats_mysql_commands_counters`
+// for commands selected by filter. Bucket columns are discovered from the result set,
+// so columns added or removed by ProxySQL versions are tolerated.
+func scrapeMySQLCommandCounterMetrics(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, filter *commandCounterFilter) error {
+	rows, err := db.QueryContext(ctx, mysqlCommandCounterQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	commandIdx, totalTimeIdx, totalCntIdx := -1, -1, -1
+	buckets := make(map[int]float64)
+	for i, column := range columns {
+		switch strings.ToLower(column) {
+		case "command":
+			commandIdx = i
+		case "total_time_us":
+			totalTimeIdx = i
+		case "total_cnt":
+			totalCntIdx = i
+		default:
+			if bound, ok := commandCounterBucket(column); ok {
+				buckets[i] = bound
+			} else {
+				logger.Debug(fmt.Sprintf("stats_mysql_commands_counters: unexpected column %s", column))
+			}
+		}
+	}
+	if commandIdx < 0 || totalTimeIdx < 0 || totalCntIdx < 0 {
+		return fmt.Errorf("stats_mysql_commands_counters: missing Command, Total_Time_us or Total_cnt column in %v", columns)
+	}
+
+	bounds := make([]float64, 0, len(buckets))
+	for _, bound := range buckets {
+		bounds = append(bounds, bound)
+	}
+	slices.Sort(bounds)
+
+	scan := make([]interface{}, len(columns))
+	for i := range scan {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
 			return err
 		}
 
+		command := scan[commandIdx].(*sql.NullString).String
+		if !filter.selected(command) {
+			continue
+		}
+
+		totalTimeUs, err := strconv.ParseFloat(scan[totalTimeIdx].(*sql.NullString).String, 64)
+		if err != nil {
+			return fmt.Errorf("stats_mysql_commands_counters: command %s: %w", command, err)
+		}
+		totalCnt, err := strconv.ParseUint(scan[totalCntIdx].(*sql.NullString).String, 10, 64)
+		if err != nil {
+			return fmt.Errorf("stats_mysql_commands_counters: command %s: %w", command, err)
+		}
+
+		counts := make(map[float64]uint64, len(bounds))
+		for i, bound := range buckets {
+			cnt, err := strconv.ParseUint(scan[i].(*sql.NullString).String, 10, 64)
+			if err != nil {
+				return fmt.Errorf("stats_mysql_commands_counters: command %s: %w", command, err)
+			}
+			counts[bound] = cnt
+		}
+
+		// ProxySQL buckets are not cumulative
+		cumulative := make(map[float64]uint64, len(bounds))
+		var sum uint64
+		for _, bound := range bounds {
+			sum += counts[bound]
+			cumulative[bound] = sum
+		}
+
 		ch <- prometheus.MustNewConstHistogram(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "mysql_command_counter", "latency_milliseconds"),
-				"histogram over a commands latency in ms",
-				[]string{"command"},
-				prometheus.Labels{},
-			),
-			res.TotalCnt, res.TotalTimeUs,
-			map[float64]uint64{
-				.1: res.cnt100us,
-				.5: res.cnt500us + res.cnt100us,
-				1:  res.cnt1ms + res.cnt500us + res.cnt100us,
-				5:  res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-				10: res.cnt10ms + res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-				50: res.cnt50ms + res.cnt10ms + res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-				100: res.cnt100ms + res.cnt50ms + res.cnt10ms + res.cnt5ms + res.cnt1ms + res.cnt500us +
-					res.cnt100us,
-				500: res.cnt500ms + res.cnt100ms + res.cnt50ms + res.cnt10ms + res.cnt5ms + res.cnt1ms +
-					res.cnt500us + res.cnt100us,
-				1000: res.cnt1s + res.cnt500ms + res.cnt100ms + res.cnt50ms + res.cnt10ms + res.cnt5ms +
-					res.cnt1ms + res.cnt500us + res.cnt100us,
-				5000: res.cnt5s + res.cnt1s + res.cnt500ms + res.cnt100ms + res.cnt50ms + res.cnt10ms +
-					res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-				10000: res.cnt10s + res.cnt5s + res.cnt1s + res.cnt500ms + res.cnt100ms + res.cnt50ms +
-					res.cnt10ms + res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-				math.Inf(1): res.cntINFs + res.cnt10s + res.cnt5s + res.cnt1s + res.cnt500ms + res.cnt100ms +
-					res.cnt50ms + res.cnt10ms + res.cnt5ms + res.cnt1ms + res.cnt500us + res.cnt100us,
-			},
-			res.Command,
+			mysqlCommandCounterDesc,
+			totalCnt, totalTimeUs,
+			cumulative,
+			command,
 		)
 	}
 
@@ -619,9 +1071,18 @@ var mySQLruntimeServersMetrics = map[string]*metric{
 		"Ping time."},
 }
 
+var mySQLruntimeServersDescs = newMetricDescs("runtime_servers", []string{"hostgroup", "endpoint", "gtid_port"}, mySQLruntimeServersMetrics,
+	"Undocumented runtime_mysql_servers metric.")
+
 // scrapeMySQLRuntimeServers collects metrics from `runtime_mysql_servers`.
-func scrapeMySQLRuntimeServers(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(mySQLruntimeServersQuery)
+func scrapeMySQLRuntimeServers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeRuntimeServers(ctx, db, ch, mySQLruntimeServersQuery, mySQLruntimeServersDescs)
+}
+
+// scrapeRuntimeServers collects metrics from servers returned by query (runtime_mysql_servers or runtime_pgsql_servers).
+// The query starts with hostgroup_id, hostname, port and other label columns; hostname and port are exported as endpoint label.
+func scrapeRuntimeServers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return err
 	}
@@ -632,11 +1093,16 @@ func scrapeMySQLRuntimeServers(db *sql.DB, ch chan<- prometheus.Metric) error {
 		return err
 	}
 
-	// first 4 columns are fixed in our SELECT statement
+	// first columns are fixed in our SELECT statement
+	fixed := len(descs.labels) + 1
 	scan := make([]interface{}, len(columns))
-	var hostgroupID, hostname, port, gtidPort string
-	scan[0], scan[1], scan[2], scan[3] = &hostgroupID, &hostname, &port, &gtidPort
-	for i := 4; i < len(scan); i++ {
+	fixedValues := make([]string, fixed)
+	fixedColumns := make(map[string]bool, fixed)
+	for i := range fixed {
+		scan[i] = &fixedValues[i]
+		fixedColumns[strings.ToLower(columns[i])] = true
+	}
+	for i := fixed; i < len(scan); i++ {
 		scan[i] = new(string)
 	}
 
@@ -647,13 +1113,14 @@ func scrapeMySQLRuntimeServers(db *sql.DB, ch chan<- prometheus.Metric) error {
 			return err
 		}
 
-		for i := 4; i < len(columns); i++ {
+		labelValues := append([]string{fixedValues[0], fixedValues[1] + ":" + fixedValues[2]}, fixedValues[3:]...)
+		for i := fixed; i < len(columns); i++ {
 			valueS = *(scan[i].(*string))
 			column = strings.ToLower(columns[i])
-			switch column {
-			case "hostgroup_id", "hostname", "port", "gtid_port":
+			switch {
+			case fixedColumns[column]:
 				continue
-			case "status":
+			case column == "status":
 				switch valueS {
 				case "ONLINE":
 					value = 1
@@ -675,23 +1142,8 @@ func scrapeMySQLRuntimeServers(db *sql.DB, ch chan<- prometheus.Metric) error {
 				}
 			}
 
-			m := mySQLruntimeServersMetrics[column]
-			if m == nil {
-				m = &metric{
-					name:      column,
-					valueType: prometheus.UntypedValue,
-					help:      "Undocumented runtime_mysql_servers metric.",
-				}
-			}
-			ch <- prometheus.MustNewConstMetric(
-				prometheus.NewDesc(
-					prometheus.BuildFQName(namespace, "runtime_servers", m.name),
-					m.help,
-					[]string{"hostgroup", "endpoint", "gtid_port"}, nil,
-				),
-				m.valueType, value,
-				hostgroupID, hostname+":"+port, gtidPort,
-			)
+			valueType, desc := descs.getOrUndocumented(column, column)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, labelValues...)
 		}
 	}
 	return rows.Err()
@@ -742,13 +1194,15 @@ var memoryMetricsMetrics = map[string]*metric{
 	},
 }
 
+var memoryMetricsDescs = newMetricDescs("stats_memory", nil, memoryMetricsMetrics, "Undocumented stats_memory_metrics metric.")
+
 type memoryMetricsResult struct {
 	name  string
 	value float64
 }
 
-func scrapeMemoryMetrics(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(memoryMetricsQuery)
+func scrapeMemoryMetrics(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, memoryMetricsQuery)
 	if err != nil {
 		return err
 	}
@@ -762,32 +1216,1344 @@ func scrapeMemoryMetrics(db *sql.DB, ch chan<- prometheus.Metric) error {
 			return err
 		}
 
-		m := memoryMetricsMetrics[strings.ToLower(res.name)]
-		if m == nil {
-			m = &metric{
-				name:      res.name,
-				valueType: prometheus.UntypedValue,
-				help:      "Undocumented stats_memory_metrics metric.",
-			}
-		}
-		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "stats_memory", m.name),
-				m.help,
-				nil, nil,
-			),
-			m.valueType,
-			res.value,
-		)
+		valueType, desc := memoryMetricsDescs.getOrUndocumented(strings.ToLower(res.name), res.name)
+		ch <- prometheus.MustNewConstMetric(desc, valueType, res.value)
 	}
 
 	return rows.Err()
 }
 
-const proxySQLVersionQuery = "select variable_value from global_variables where variable_name = 'admin-version'"
+// Digests are aggregated over client_address (populated only when mysql-query_digests_track_hostname is enabled)
+// so that every hostgroup, schemaname, username and digest combination is exported once.
+const mySQLQueryDigestQuery = `
+    SELECT
+        hostgroup, schemaname, username, digest,
+        SUM(count_star) AS count_star, SUM(sum_time) AS sum_time, MIN(min_time) AS min_time, MAX(max_time) AS max_time,
+        SUM(sum_rows_affected) AS sum_rows_affected, SUM(sum_rows_sent) AS sum_rows_sent
+    FROM
+        stats_mysql_query_digest
+    %s
+    GROUP BY hostgroup, schemaname, username, digest
+    ORDER BY %s DESC
+    LIMIT %d
+`
+
+// mySQLQueryDigestOrderBy contains columns which can be used to select top-N digests.
+var mySQLQueryDigestOrderBy = map[string]bool{
+	"sum_time":   true,
+	"count_star": true,
+}
+
+// https://github.com/sysown/proxysql/blob/master/doc/admin_tables.md#stats_mysql_query_digest
+// key - column name in lowercase.
+var mySQLQueryDigestMetrics = map[string]*metric{
+	"count_star": {"count_star", prometheus.CounterValue,
+		"The total number of times the query has been executed (with different values for the parameters)."},
+	"sum_time": {"sum_time_us", prometheus.CounterValue,
+		"The total time in microseconds spent executing queries of this type."},
+	"min_time": {"min_time_us", prometheus.GaugeValue,
+		"The minimum duration in microseconds spent executing a query of this type."},
+	"max_time": {"max_time_us", prometheus.GaugeValue,
+		"The maximum duration in microseconds spent executing a query of this type."},
+	"sum_rows_affected": {"sum_rows_affected", prometheus.CounterValue,
+		"The total number of rows affected by queries of this type."},
+	"sum_rows_sent": {"sum_rows_sent", prometheus.CounterValue,
+		"The total number of rows sent by queries of this type."},
+}
+
+var mySQLQueryDigestDescs = newMetricDescs("query_digest", []string{"hostgroup", "schemaname", "username", "digest"},
+	mySQLQueryDigestMetrics, "")
+
+// mySQLQueryDigestWhere returns WHERE clause restricting digests to given schemas and users (if not empty).
+func mySQLQueryDigestWhere(schemanames, usernames []string) string {
+	var conds []string
+	for column, values := range map[string][]string{"schemaname": schemanames, "username": usernames} {
+		if len(values) == 0 {
+			continue
+		}
+		quoted := make([]string, len(values))
+		for i, v := range values {
+			quoted[i] = "'" + strings.ReplaceAll(v, "'", "''") + "'"
+		}
+		conds = append(conds, fmt.Sprintf("%s IN (%s)", column, strings.Join(quoted, ", ")))
+	}
+	if len(conds) == 0 {
+		return ""
+	}
+	slices.Sort(conds)
+	return "WHERE " + strings.Join(conds, " AND ")
+}
+
+// scrapeMySQLQueryDigest collects metrics from `stats_mysql_query_digest`.
+// Only limit digests with the highest orderBy column value are exported to keep cardinality bounded.
+// If schemanames or usernames are not empty, only digests of those schemas and users are considered.
+func scrapeMySQLQueryDigest(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, limit int, orderBy string, schemanames, usernames []string) error {
+	where := mySQLQueryDigestWhere(schemanames, usernames)
+	return scrapeQueryDigest(ctx, db, ch, "stats_mysql_query_digest", mySQLQueryDigestQuery, mySQLQueryDigestDescs, limit, orderBy, where)
+}
+
+// scrapeQueryDigest collects top-N digests from table (stats_mysql_query_digest or stats_pgsql_query_digest).
+// The query has placeholders for WHERE clause, ORDER BY column and LIMIT, and starts with label columns.
+func scrapeQueryDigest(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, table, query string, descs *metricDescs,
+	limit int, orderBy, where string,
+) error {
+	if !mySQLQueryDigestOrderBy[orderBy] {
+		return fmt.Errorf("invalid %s order column %q", table, orderBy)
+	}
+	if limit <= 0 {
+		return fmt.Errorf("invalid %s limit %d", table, limit)
+	}
+
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(query, where, orderBy, limit))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// first columns are fixed in our SELECT statement
+	fixed := len(descs.labels)
+	scan := make([]interface{}, len(columns))
+	labelValues := make([]string, fixed)
+	for i := range fixed {
+		scan[i] = &labelValues[i]
+	}
+	for i := fixed; i < len(scan); i++ {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i := fixed; i < len(columns); i++ {
+			column := strings.ToLower(columns[i])
+			m, desc := descs.get(column)
+			if m == nil {
+				continue
+			}
+
+			valueS := scan[i].(*sql.NullString)
+			if !valueS.Valid {
+				continue
+			}
+			value, err := strconv.ParseFloat(valueS.String, 64)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("column %s: %s", column, err))
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(desc, m.valueType, value, labelValues...)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	mySQLQueryRulesStatsQuery   = "SELECT rule_id, hits FROM stats_mysql_query_rules"
+	mySQLQueryRulesRuntimeQuery = "SELECT rule_id, destination_hostgroup, match_digest, match_pattern, comment FROM runtime_mysql_query_rules"
+)
+
+var mySQLQueryRulesHitsDesc = newDesc("query_rules", "hits_total",
+	"The total number of times the query rule was matched.",
+	[]string{"rule_id", "destination_hostgroup", "match_digest", "match_pattern", "comment"},
+)
+
+// mySQLQueryRule contains runtime_mysql_query_rules columns used as labels.
+type mySQLQueryRule struct {
+	destinationHostgroup, matchDigest, matchPattern, comment sql.NullString
+}
+
+// scrapeMySQLQueryRules collects metrics from `stats_mysql_query_rules`.
+// Rules are labeled with columns from `runtime_mysql_query_rules`; if permissions are insufficient to read it,
+// hits are still exported with empty labels.
+func scrapeMySQLQueryRules(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rules, err := queryMySQLRuntimeQueryRules(ctx, db)
+	if err != nil {
+		if !isPermissionError(err) {
+			return err
+		}
+		logger.Debug("Error scraping runtime_mysql_query_rules, labels are skipped", "error", err)
+	}
+
+	rows, err := db.QueryContext(ctx, mySQLQueryRulesStatsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var ruleID string
+	var hits float64
+	for rows.Next() {
+		if err = rows.Scan(&ruleID, &hits); err != nil {
+			return err
+		}
+
+		rule := rules[ruleID]
+		ch <- prometheus.MustNewConstMetric(
+			mySQLQueryRulesHitsDesc,
+			prometheus.CounterValue, hits,
+			ruleID, rule.destinationHostgroup.String, rule.matchDigest.String, rule.matchPattern.String, rule.comment.String,
+		)
+	}
+	return rows.Err()
+}
+
+// queryMySQLRuntimeQueryRules returns runtime_mysql_query_rules indexed by rule_id.
+func queryMySQLRuntimeQueryRules(ctx context.Context, db *sql.DB) (map[string]mySQLQueryRule, error) {
+	rows, err := db.QueryContext(ctx, mySQLQueryRulesRuntimeQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rules := make(map[string]mySQLQueryRule)
+	for rows.Next() {
+		var ruleID string
+		var rule mySQLQueryRule
+		if err = rows.Scan(&ruleID, &rule.destinationHostgroup, &rule.matchDigest, &rule.matchPattern, &rule.comment); err != nil {
+			return nil, err
+		}
+		rules[ruleID] = rule
+	}
+	return rules, rows.Err()
+}
+
+const mySQLErrorsQuery = "SELECT hostgroup, hostname, port, username, schemaname, errno, count_star, first_seen, last_seen, last_error FROM stats_mysql_errors"
+
+// mySQLErrorsLastErrorMaxLength is the maximum length of last_error label value.
+const mySQLErrorsLastErrorMaxLength = 128
+
+var (
+	mySQLErrorsQuotedRE = regexp.MustCompile("'[^']*'|\"[^\"]*\"|`[^`]*`")
+	mySQLErrorsNumberRE = regexp.MustCompile(`\b\d+\b`)
+)
+
+// normalizeMySQLError replaces quoted values and numbers in error message with `?`
+// and truncates it to keep last_error label cardinality bounded.
+func normalizeMySQLError(s string) string {
+	s = mySQLErrorsQuotedRE.ReplaceAllString(s, "?")
+	s = mySQLErrorsNumberRE.ReplaceAllString(s, "?")
+	s = strings.Join(strings.Fields(s), " ")
+	if r := []rune(s); len(r) > mySQLErrorsLastErrorMaxLength {
+		s = string(r[:mySQLErrorsLastErrorMaxLength])
+	}
+	return s
+}
+
+type mySQLErrorsResult struct {
+	hostgroup, endpoint, username, schemaname, errno, lastError string
+	countStar, firstSeen, lastSeen                              float64
+}
+
+var (
+	mySQLErrorsLabels = []string{"hostgroup", "endpoint", "username", "schemaname", "errno", "last_error"}
+
+	mySQLErrorsTotalDesc = newDesc("mysql_errors", "total",
+		"The total number of times the error was returned by the backend server.",
+		mySQLErrorsLabels,
+	)
+	mySQLErrorsFirstSeenDesc = newDesc("mysql_errors", "first_seen_timestamp_seconds",
+		"Unix timestamp when the error was seen for the first time.",
+		mySQLErrorsLabels,
+	)
+	mySQLErrorsLastSeenDesc = newDesc("mysql_errors", "last_seen_timestamp_seconds",
+		"Unix timestamp when the error was seen for the last time.",
+		mySQLErrorsLabels,
+	)
+)
+
+// scrapeMySQLErrors collects metrics from `stats_mysql_errors`.
+// Rows which differ only by client address or by non-normalized error message are merged.
+func scrapeMySQLErrors(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, mySQLErrorsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var results []*mySQLErrorsResult
+	index := make(map[mySQLErrorsResult]*mySQLErrorsResult)
+	for rows.Next() {
+		var res mySQLErrorsResult
+		var hostname, port string
+		err = rows.Scan(&res.hostgroup, &hostname, &port, &res.username, &res.schemaname, &res.errno,
+			&res.countStar, &res.firstSeen, &res.lastSeen, &res.lastError)
+		if err != nil {
+			return err
+		}
+		res.endpoint = hostname + ":" + port
+		res.lastError = normalizeMySQLError(res.lastError)
+
+		key := mySQLErrorsResult{
+			hostgroup: res.hostgroup, endpoint: res.endpoint, username: res.username,
+			schemaname: res.schemaname, errno: res.errno, lastError: res.lastError,
+		}
+		if prev := index[key]; prev != nil {
+			prev.countStar += res.countStar
+			prev.firstSeen = math.Min(prev.firstSeen, res.firstSeen)
+			prev.lastSeen = math.Max(prev.lastSeen, res.lastSeen)
+			continue
+		}
+		index[key] = &res
+		results = append(results, &res)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	for _, res := range results {
+		labelValues := []string{res.hostgroup, res.endpoint, res.username, res.schemaname, res.errno, res.lastError}
+		ch <- prometheus.MustNewConstMetric(mySQLErrorsTotalDesc, prometheus.CounterValue, res.countStar, labelValues...)
+		ch <- prometheus.MustNewConstMetric(mySQLErrorsFirstSeenDesc, prometheus.GaugeValue, res.firstSeen, labelValues...)
+		ch <- prometheus.MustNewConstMetric(mySQLErrorsLastSeenDesc, prometheus.GaugeValue, res.lastSeen, labelValues...)
+	}
+	return nil
+}
+
+// monitorLog describes Monitor module log table.
+// Every query returns hostname, port, time_start_us, checked value and error of the most recent check per server.
+type monitorLog struct {
+	table string
+	query string
+	value *metric
+
+	valueDesc, errorDesc, timestampDesc *prometheus.Desc
+}
+
+// newMonitorLogs returns monitor logs with descriptors of their metrics.
+func newMonitorLogs(logs ...monitorLog) []monitorLog {
+	labels := []string{"endpoint"}
+	for i, l := range logs {
+		logs[i].valueDesc = newDesc("monitor", l.value.name, l.value.help, labels)
+		logs[i].errorDesc = newDesc("monitor", l.table+"_error",
+			fmt.Sprintf("Whether the most recent %s check resulted in an error (1 for error, 0 for success).", l.table),
+			labels,
+		)
+		logs[i].timestampDesc = newDesc("monitor", l.table+"_timestamp_seconds",
+			fmt.Sprintf("Unix timestamp of the most recent %s check.", l.table),
+			labels,
+		)
+	}
+	return logs
+}
+
+// SQLite returns values from the row with MAX(time_start_us) for bare columns.
+// https://github.com/sysown/proxysql/wiki/Monitor-Module
+var monitorLogs = newMonitorLogs(
+	monitorLog{
+		table: "ping",
+		query: "SELECT hostname, port, MAX(time_start_us), ping_success_time_us, ping_error FROM monitor.mysql_server_ping_log GROUP BY hostname, port",
+		value: &metric{"ping_latency_us", prometheus.GaugeValue,
+			"Latency in microseconds of the most recent ping check."},
+	},
+	monitorLog{
+		table: "connect",
+		query: "SELECT hostname, port, MAX(time_start_us), connect_success_time_us, connect_error FROM monitor.mysql_server_connect_log GROUP BY hostname, port",
+		value: &metric{"connect_latency_us", prometheus.GaugeValue,
+			"Latency in microseconds of the most recent connect check."},
+	},
+	monitorLog{
+		table: "read_only",
+		query: "SELECT hostname, port, MAX(time_start_us), read_only, error FROM monitor.mysql_server_read_only_log GROUP BY hostname, port",
+		value: &metric{"read_only", prometheus.GaugeValue,
+			"The read_only value observed by the most recent read_only check."},
+	},
+	monitorLog{
+		table: "replication_lag",
+		query: "SELECT hostname, port, MAX(time_start_us), repl_lag, error FROM monitor.mysql_server_replication_lag_log GROUP BY hostname, port",
+		value: &metric{"replication_lag_seconds", prometheus.GaugeValue,
+			"Replication lag in seconds reported by the most recent replication lag check."},
+	},
+)
+
+// scrapeMonitor collects metrics from Monitor module `monitor.mysql_server_*_log` tables.
+func scrapeMonitor(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	for _, l := range monitorLogs {
+		if err := scrapeMonitorLog(ctx, db, ch, l); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func scrapeMonitorLog(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, l monitorLog) error {
+	rows, err := db.QueryContext(ctx, l.query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	var hostname, port string
+	var timeStartUs float64
+	var value sql.NullFloat64
+	var checkError sql.NullString
+	for rows.Next() {
+		if err = rows.Scan(&hostname, &port, &timeStartUs, &value, &checkError); err != nil {
+			return err
+		}
+		endpoint := hostname + ":" + port
+
+		// value is NULL if the check failed
+		if value.Valid {
+			ch <- prometheus.MustNewConstMetric(l.valueDesc, l.value.valueType, value.Float64, endpoint)
+		}
+
+		var errored float64
+		if checkError.Valid && checkError.String != "" {
+			errored = 1
+		}
+		ch <- prometheus.MustNewConstMetric(l.errorDesc, prometheus.GaugeValue, errored, endpoint)
+		ch <- prometheus.MustNewConstMetric(l.timestampDesc, prometheus.GaugeValue, timeStartUs/1e6, endpoint)
+	}
+	return rows.Err()
+}
+
+// topologyHostgroups describes runtime_mysql_*_hostgroups table.
+type topologyHostgroups struct {
+	table      string
+	subsystem  string
+	hostgroups []string // hostgroup columns exported as info metric labels, the first one identifies the row
+	metrics    map[string]*metric
+
+	infoDesc *prometheus.Desc
+	descs    *metricDescs
+}
+
+// newTopologyHostgroups returns t with descriptors of its metrics.
+func newTopologyHostgroups(t topologyHostgroups) topologyHostgroups {
+	t.infoDesc = newDesc(t.subsystem, "info", fmt.Sprintf("Hostgroups configured in %s.", t.table), t.hostgroups)
+	t.descs = newMetricDescs(t.subsystem, t.hostgroups[:1], t.metrics, fmt.Sprintf("Undocumented %s metric.", t.table))
+	return t
+}
+
+// topologyLog describes Monitor module log table for cluster topology checks.
+// The query returns label value, MAX(time_start_us) and checked values of the most recent check.
+type topologyLog struct {
+	subsystem string
+	query     string
+	label     string
+	metrics   map[string]*metric
+
+	descs *metricDescs
+}
+
+// newTopologyLog returns l with descriptors of its metrics.
+func newTopologyLog(l topologyLog) topologyLog {
+	l.descs = newMetricDescs(l.subsystem, []string{l.label}, l.metrics, "")
+	return l
+}
+
+// https://proxysql.com/documentation/main-runtime/#mysql_group_replication_hostgroups
+var groupReplicationHostgroups = newTopologyHostgroups(topologyHostgroups{
+	table:      "runtime_mysql_group_replication_hostgroups",
+	subsystem:  "group_replication_hostgroups",
+	hostgroups: []string{"writer_hostgroup", "backup_writer_hostgroup", "reader_hostgroup", "offline_hostgroup"},
+	metrics: map[string]*metric{
+		"active": {"active", prometheus.GaugeValue,
+			"If the value is 1, ProxySQL monitors the hostgroups and moves servers between them."},
+		"max_writers": {"max_writers", prometheus.GaugeValue,
+			"The maximum number of nodes allowed in the writer hostgroup."},
+		"writer_is_also_reader": {"writer_is_also_reader", prometheus.GaugeValue,
+			"If the value is 1, nodes in the writer hostgroup are also placed in the reader hostgroup."},
+		"max_transactions_behind": {"max_transactions_behind", prometheus.GaugeValue,
+			"The maximum number of transactions behind the writers before a node is shunned."},
+	},
+})
+
+// https://proxysql.com/documentation/main-runtime/#mysql_galera_hostgroups
+var galeraHostgroups = newTopologyHostgroups(topologyHostgroups{
+	table:      "runtime_mysql_galera_hostgroups",
+	subsystem:  "galera_hostgroups",
+	hostgroups: []string{"writer_hostgroup", "backup_writer_hostgroup", "reader_hostgroup", "offline_hostgroup"},
+	metrics:    groupReplicationHostgroups.metrics,
+})
+
+// https://proxysql.com/documentation/aws-aurora-configuration/
+var awsAuroraHostgroups = newTopologyHostgroups(topologyHostgroups{
+	table:      "runtime_mysql_aws_aurora_hostgroups",
+	subsystem:  "aws_aurora_hostgroups",
+	hostgroups: []string{"writer_hostgroup", "reader_hostgroup"},
+	metrics: map[string]*metric{
+		"active": {"active", prometheus.GaugeValue,
+			"If the value is 1, ProxySQL monitors the hostgroups and moves servers between them."},
+		"aurora_port": {"aurora_port", prometheus.GaugeValue,
+			"The port used to connect to the Aurora instances."},
+		"max_lag_ms": {"max_lag_ms", prometheus.GaugeValue,
+			"The maximum replication lag in milliseconds before a reader is shunned."},
+		"check_interval_ms": {"check_interval_ms", prometheus.GaugeValue,
+			"How often in milliseconds the Aurora topology is checked."},
+		"check_timeout_ms": {"check_timeout_ms", prometheus.GaugeValue,
+			"The timeout in milliseconds of the Aurora topology check."},
+		"writer_is_also_reader": {"writer_is_also_reader", prometheus.GaugeValue,
+			"If the value is 1, the writer is also placed in the reader hostgroup."},
+		"new_reader_weight": {"new_reader_weight", prometheus.GaugeValue,
+			"The weight assigned to newly discovered readers."},
+		"add_lag_ms": {"add_lag_ms", prometheus.GaugeValue,
+			"The replication lag in milliseconds added to the measured value."},
+		"min_lag_ms": {"min_lag_ms", prometheus.GaugeValue,
+			"The minimum replication lag in milliseconds reported for readers."},
+		"lag_num_checks": {"lag_num_checks", prometheus.GaugeValue,
+			"The number of checks used to compute replication lag."},
+	},
+})
+
+var groupReplicationLog = newTopologyLog(topologyLog{
+	subsystem: "group_replication",
+	query: `
+    SELECT hostname || ':' || port AS endpoint, MAX(time_start_us), viable_candidate, read_only, transactions_behind
+    FROM monitor.mysql_server_group_replication_log
+    GROUP BY hostname, port
+`,
+	label: "endpoint",
+	metrics: map[string]*metric{
+		"viable_candidate": {"viable_candidate", prometheus.GaugeValue,
+			"Whether the node is a viable candidate to be a writer (1 - YES, 0 - NO)."},
+		"read_only": {"read_only", prometheus.GaugeValue,
+			"Whether the node has super_read_only enabled (1 - YES, 0 - NO)."},
+		"transactions_behind": {"transactions_behind", prometheus.GaugeValue,
+			"The number of transactions in the node applier queue."},
+	},
+})
+
+var galeraLog = newTopologyLog(topologyLog{
+	subsystem: "galera",
+	query: `
+    SELECT hostname || ':' || port AS endpoint, MAX(time_start_us), primary_partition, read_only, wsrep_local_recv_queue,
+        wsrep_local_state, wsrep_desync, wsrep_reject_queries, wsrep_sst_donor_rejects_queries, pxc_maint_mode
+    FROM monitor.mysql_server_galera_log
+    GROUP BY hostname, port
+`,
+	label: "endpoint",
+	metrics: map[string]*metric{
+		"primary_partition": {"primary_partition", prometheus.GaugeValue,
+			"Whether the node is part of the primary component (1 - YES, 0 - NO)."},
+		"read_only": {"read_only", prometheus.GaugeValue,
+			"Whether the node has read_only enabled (1 - YES, 0 - NO)."},
+		"wsrep_local_recv_queue": {"wsrep_local_recv_queue", prometheus.GaugeValue,
+			"The length of the node receive queue."},
+		"wsrep_local_state": {"wsrep_local_state", prometheus.GaugeValue,
+			"The node state (1 - Joining, 2 - Donor/Desynced, 3 - Joined, 4 - Synced)."},
+		"wsrep_desync": {"wsrep_desync", prometheus.GaugeValue,
+			"Whether the node is desynced (1 - YES, 0 - NO)."},
+		"wsrep_reject_queries": {"wsrep_reject_queries", prometheus.GaugeValue,
+			"Whether the node rejects queries (1 - YES, 0 - NO)."},
+		"wsrep_sst_donor_rejects_queries": {"wsrep_sst_donor_rejects_queries", prometheus.GaugeValue,
+			"Whether the node rejects queries while being an SST donor (1 - YES, 0 - NO)."},
+		"pxc_maint_mode": {"pxc_maint_mode", prometheus.GaugeValue,
+			"Whether the node is in maintenance mode (1 - YES, 0 - NO)."},
+	},
+})
+
+// Every check returns a row for every instance in the Aurora cluster, so the most recent row per instance is used.
+var awsAuroraLog = newTopologyLog(topologyLog{
+	subsystem: "aws_aurora",
+	query: `
+    SELECT server_id, MAX(time_start_us), replica_lag_in_milliseconds, cpu
+    FROM monitor.mysql_server_aws_aurora_log
+    WHERE server_id IS NOT NULL
+    GROUP BY server_id
+`,
+	label: "server_id",
+	metrics: map[string]*metric{
+		"replica_lag_in_milliseconds": {"replica_lag_ms", prometheus.GaugeValue,
+			"Replication lag in milliseconds reported by Aurora."},
+		"cpu": {"cpu", prometheus.GaugeValue,
+			"CPU utilization in percent reported by Aurora."},
+	},
+})
+
+// scrapeGroupReplication collects metrics from `runtime_mysql_group_replication_hostgroups`
+// and `monitor.mysql_server_group_replication_log`.
+func scrapeGroupReplication(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeTopology(ctx, db, ch, groupReplicationHostgroups, groupReplicationLog)
+}
+
+// scrapeGalera collects metrics from `runtime_mysql_galera_hostgroups` and `monitor.mysql_server_galera_log`.
+func scrapeGalera(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeTopology(ctx, db, ch, galeraHostgroups, galeraLog)
+}
+
+// scrapeAWSAurora collects metrics from `runtime_mysql_aws_aurora_hostgroups` and `monitor.mysql_server_aws_aurora_log`.
+func scrapeAWSAurora(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeTopology(ctx, db, ch, awsAuroraHostgroups, awsAuroraLog)
+}
+
+func scrapeTopology(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, hostgroups topologyHostgroups, log topologyLog) error {
+	if err := scrapeTopologyHostgroups(ctx, db, ch, hostgroups); err != nil {
+		return err
+	}
+	return scrapeTopologyLog(ctx, db, ch, log)
+}
+
+// parseTopologyValue converts YES/NO flags and numbers to float.
+func parseTopologyValue(valueS string) (float64, error) {
+	switch strings.ToUpper(valueS) {
+	case "YES":
+		return 1, nil
+	case "NO":
+		return 0, nil
+	default:
+		return strconv.ParseFloat(valueS, 64)
+	}
+}
+
+func scrapeTopologyHostgroups(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, t topologyHostgroups) error {
+	rows, err := db.QueryContext(ctx, "SELECT * FROM "+t.table)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	scan := make([]interface{}, len(columns))
+	for i := range scan {
+		scan[i] = new(sql.NullString)
+	}
+
+	hostgroupValues := make(map[string]string, len(t.hostgroups))
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i, column := range columns {
+			hostgroupValues[strings.ToLower(column)] = scan[i].(*sql.NullString).String
+		}
+		labelValues := make([]string, len(t.hostgroups))
+		for i, hostgroup := range t.hostgroups {
+			labelValues[i] = hostgroupValues[hostgroup]
+		}
+		ch <- prometheus.MustNewConstMetric(t.infoDesc, prometheus.GaugeValue, 1, labelValues...)
+
+		for i, column := range columns {
+			column = strings.ToLower(column)
+			valueS := scan[i].(*sql.NullString)
+			if !valueS.Valid || slices.Contains(t.hostgroups, column) {
+				continue
+			}
+			value, err := strconv.ParseFloat(valueS.String, 64)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("column %s: %s", column, err))
+				continue
+			}
+
+			valueType, desc := t.descs.getOrUndocumented(column, column)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, labelValues[0])
+		}
+	}
+	return rows.Err()
+}
+
+func scrapeTopologyLog(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, l topologyLog) error {
+	rows, err := db.QueryContext(ctx, l.query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// first 2 columns are label value and MAX(time_start_us)
+	scan := make([]interface{}, len(columns))
+	var labelValue string
+	scan[0], scan[1] = &labelValue, new(sql.NullString)
+	for i := 2; i < len(scan); i++ {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i := 2; i < len(columns); i++ {
+			column := strings.ToLower(columns[i])
+			m, desc := l.descs.get(column)
+			valueS := scan[i].(*sql.NullString)
+			// values are NULL if the check failed
+			if m == nil || !valueS.Valid {
+				continue
+			}
+			value, err := parseTopologyValue(valueS.String)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("column %s: %s", column, err))
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(desc, m.valueType, value, labelValue)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	proxySQLClusterChecksumsQuery      = "SELECT hostname, port, name, version, epoch, checksum, diff_check FROM stats_proxysql_servers_checksums"
+	proxySQLClusterLocalChecksumsQuery = "SELECT name, checksum FROM runtime_checksums_values"
+	proxySQLClusterMetricsQuery        = "SELECT hostname, port, * FROM stats_proxysql_servers_metrics"
+	proxySQLClusterStatusQuery         = "SELECT hostname, port, * FROM stats_proxysql_servers_status"
+)
+
+// https://proxysql.com/documentation/proxysql-cluster/
+// key - column name in lowercase.
+var proxySQLClusterMetricsMetrics = map[string]*metric{
+	"weight": {"weight", prometheus.GaugeValue,
+		"The weight of the peer configured in proxysql_servers."},
+	"response_time_ms": {"response_time_ms", prometheus.GaugeValue,
+		"The response time in milliseconds of the most recent metrics check of the peer."},
+	"uptime_s": {"uptime_seconds", prometheus.CounterValue,
+		"The peer uptime in seconds."},
+	"last_check_ms": {"last_check_ms", prometheus.GaugeValue,
+		"The time in milliseconds since the most recent metrics check of the peer."},
+	"queries": {"queries", prometheus.CounterValue,
+		"The total number of queries executed by the peer."},
+	"client_connections_connected": {"client_connections_connected", prometheus.GaugeValue,
+		"The current number of frontend connections of the peer."},
+	"client_connections_created": {"client_connections_created", prometheus.CounterValue,
+		"The total number of frontend connections created by the peer."},
+}
+
+// key - column name in lowercase.
+var proxySQLClusterStatusMetrics = map[string]*metric{
+	"weight": {"weight", prometheus.GaugeValue,
+		"The weight of the peer configured in proxysql_servers."},
+	"master": {"master", prometheus.GaugeValue,
+		"Whether the peer is considered the master."},
+	"global_version": {"global_version", prometheus.GaugeValue,
+		"The global configuration version of the peer."},
+	"check_age_us": {"check_age_us", prometheus.GaugeValue,
+		"The time in microseconds since the most recent status check of the peer."},
+	"ping_time_us": {"ping_time_us", prometheus.GaugeValue,
+		"The ping time in microseconds of the peer."},
+	"checks_ok": {"checks_ok", prometheus.CounterValue,
+		"The total number of successful checks of the peer."},
+	"checks_err": {"checks_err", prometheus.CounterValue,
+		"The total number of failed checks of the peer."},
+}
+
+var (
+	proxySQLClusterMetricsDescs = newMetricDescs("cluster_peer", []string{"endpoint"}, proxySQLClusterMetricsMetrics,
+		"Undocumented ProxySQL Cluster peer metric.")
+	proxySQLClusterStatusDescs = newMetricDescs("cluster_peer_status", []string{"endpoint"}, proxySQLClusterStatusMetrics,
+		"Undocumented ProxySQL Cluster peer metric.")
+
+	proxySQLClusterChecksumLabels = []string{"endpoint", "module"}
+
+	proxySQLClusterChecksumVersionDesc = newDesc("cluster", "checksum_version",
+		"The configuration version of the module reported by the peer.",
+		proxySQLClusterChecksumLabels,
+	)
+	proxySQLClusterChecksumEpochDesc = newDesc("cluster", "checksum_epoch",
+		"Unix timestamp when the configuration of the module was loaded on the peer.",
+		proxySQLClusterChecksumLabels,
+	)
+	proxySQLClusterChecksumDiffCheckDesc = newDesc("cluster", "checksum_diff_check",
+		"The number of consecutive checks in which the peer checksum of the module differed from the local one.",
+		proxySQLClusterChecksumLabels,
+	)
+	proxySQLClusterChecksumMatchesDesc = newDesc("cluster", "checksum_matches",
+		"Whether the peer checksum of the module matches the local one (1 for match, 0 for mismatch).",
+		proxySQLClusterChecksumLabels,
+	)
+	proxySQLClusterConfigInSyncDesc = newDesc("cluster", "config_in_sync",
+		"Whether all peers report the same module checksums as the local node (1 for in sync, 0 for drift).",
+		nil,
+	)
+)
+
+// scrapeProxySQLCluster collects metrics from `stats_proxysql_servers_checksums`, `stats_proxysql_servers_metrics`
+// and `stats_proxysql_servers_status`. Peer checksums are compared with `runtime_checksums_values` of the local node;
+// if permissions are insufficient to read it, comparison metrics are skipped.
+func scrapeProxySQLCluster(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	local, err := queryProxySQLClusterLocalChecksums(ctx, db)
+	if err != nil {
+		if !isPermissionError(err) {
+			return err
+		}
+		logger.Debug("Error scraping runtime_checksums_values, checksum comparison is skipped", "error", err)
+	}
+
+	if err = scrapeProxySQLClusterChecksums(ctx, db, ch, local); err != nil {
+		return err
+	}
+	if err = scrapeProxySQLClusterPeers(ctx, db, ch, proxySQLClusterMetricsQuery, proxySQLClusterMetricsDescs); err != nil {
+		return err
+	}
+	return scrapeProxySQLClusterPeers(ctx, db, ch, proxySQLClusterStatusQuery, proxySQLClusterStatusDescs)
+}
+
+// queryProxySQLClusterLocalChecksums returns checksums of the local node indexed by module name.
+func queryProxySQLClusterLocalChecksums(ctx context.Context, db *sql.DB) (map[string]string, error) {
+	rows, err := db.QueryContext(ctx, proxySQLClusterLocalChecksumsQuery)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	checksums := make(map[string]string)
+	for rows.Next() {
+		var name string
+		var checksum sql.NullString
+		if err = rows.Scan(&name, &checksum); err != nil {
+			return nil, err
+		}
+		checksums[name] = checksum.String
+	}
+	return checksums, rows.Err()
+}
+
+func scrapeProxySQLClusterChecksums(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, local map[string]string) error {
+	rows, err := db.QueryContext(ctx, proxySQLClusterChecksumsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	inSync := true
+	for rows.Next() {
+		var hostname, port, module string
+		var version, epoch, diffCheck float64
+		var checksum sql.NullString
+		if err = rows.Scan(&hostname, &port, &module, &version, &epoch, &checksum, &diffCheck); err != nil {
+			return err
+		}
+		endpoint := hostname + ":" + port
+
+		ch <- prometheus.MustNewConstMetric(proxySQLClusterChecksumVersionDesc, prometheus.GaugeValue, version, endpoint, module)
+		ch <- prometheus.MustNewConstMetric(proxySQLClusterChecksumEpochDesc, prometheus.GaugeValue, epoch, endpoint, module)
+		ch <- prometheus.MustNewConstMetric(proxySQLClusterChecksumDiffCheckDesc, prometheus.GaugeValue, diffCheck, endpoint, module)
+
+		localChecksum, ok := local[module]
+		if !ok {
+			continue
+		}
+		var matches float64
+		if checksum.String == localChecksum {
+			matches = 1
+		} else {
+			inSync = false
+		}
+		ch <- prometheus.MustNewConstMetric(proxySQLClusterChecksumMatchesDesc, prometheus.GaugeValue, matches, endpoint, module)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	if local != nil {
+		var value float64
+		if inSync {
+			value = 1
+		}
+		ch <- prometheus.MustNewConstMetric(proxySQLClusterConfigInSyncDesc, prometheus.GaugeValue, value)
+	}
+	return nil
+}
+
+func scrapeProxySQLClusterPeers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// first 2 columns are fixed in our SELECT statement
+	scan := make([]interface{}, len(columns))
+	var hostname, port string
+	scan[0], scan[1] = &hostname, &port
+	for i := 2; i < len(scan); i++ {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i := 2; i < len(columns); i++ {
+			column := strings.ToLower(columns[i])
+			valueS := scan[i].(*sql.NullString)
+			if !valueS.Valid || column == "hostname" || column == "port" || column == "comment" {
+				continue
+			}
+			value, err := strconv.ParseFloat(valueS.String, 64)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("column %s: %s", column, err))
+				continue
+			}
+
+			valueType, desc := descs.getOrUndocumented(column, column)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, hostname+":"+port)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	mySQLUsersQuery        = "SELECT username, * FROM stats_mysql_users"
+	mySQLRuntimeUsersQuery = "SELECT username, default_hostgroup, transaction_persistent, active FROM runtime_mysql_users WHERE frontend = 1"
+)
+
+// https://proxysql.com/documentation/stats-statistics/#stats_mysql_users
+// key - column name in lowercase.
+var mySQLUsersMetrics = map[string]*metric{
+	"frontend_connections": {"frontend_connections", prometheus.GaugeValue,
+		"The number of connections currently used by the user."},
+	"frontend_max_connections": {"frontend_max_connections", prometheus.GaugeValue,
+		"The maximum number of connections the user is allowed to use."},
+}
+
+// https://proxysql.com/documentation/main-runtime/#mysql_users
+// key - column name in lowercase.
+var mySQLRuntimeUsersMetrics = map[string]*metric{
+	"default_hostgroup": {"default_hostgroup", prometheus.GaugeValue,
+		"The hostgroup where traffic of the user is sent by default."},
+	"transaction_persistent": {"transaction_persistent", prometheus.GaugeValue,
+		"If the value is 1, transactions started by the user stay in the same hostgroup."},
+	"active": {"active", prometheus.GaugeValue,
+		"If the value is 1, the user is allowed to connect."},
+}
+
+var (
+	mySQLUsersDescs = newMetricDescs("mysql_users", []string{"username"}, mySQLUsersMetrics,
+		"Undocumented stats_mysql_users metric.")
+	mySQLRuntimeUsersDescs = newMetricDescs("mysql_users", []string{"username"}, mySQLRuntimeUsersMetrics,
+		"Undocumented runtime_mysql_users metric.")
+)
+
+// scrapeMySQLUsers collects metrics from `stats_mysql_users`, and from `runtime_mysql_users` if runtimeUsers is true.
+func scrapeMySQLUsers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, runtimeUsers bool) error {
+	if err := scrapeMySQLUsersTable(ctx, db, ch, mySQLUsersQuery, mySQLUsersDescs); err != nil {
+		return err
+	}
+	if !runtimeUsers {
+		return nil
+	}
+
+	err := scrapeMySQLUsersTable(ctx, db, ch, mySQLRuntimeUsersQuery, mySQLRuntimeUsersDescs)
+	if isPermissionError(err) {
+		// see runtime_mysql_servers
+		logger.Debug("Error scraping runtime_mysql_users", "error", err)
+		return nil
+	}
+	return err
+}
+
+func scrapeMySQLUsersTable(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, query string, descs *metricDescs) error {
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	// first column is fixed in our SELECT statement
+	scan := make([]interface{}, len(columns))
+	var username string
+	scan[0] = &username
+	for i := 1; i < len(scan); i++ {
+		scan[i] = new(sql.NullString)
+	}
+
+	for rows.Next() {
+		if err = rows.Scan(scan...); err != nil {
+			return err
+		}
+
+		for i := 1; i < len(columns); i++ {
+			column := strings.ToLower(columns[i])
+			valueS := scan[i].(*sql.NullString)
+			if column == "username" || !valueS.Valid {
+				continue
+			}
+			value, err := strconv.ParseFloat(valueS.String, 64)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("column %s: %s", column, err))
+				continue
+			}
+
+			valueType, desc := descs.getOrUndocumented(column, column)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, value, username)
+		}
+	}
+	return rows.Err()
+}
+
+const (
+	mySQLClientHostCacheQuery        = "SELECT client_address, error_count, last_updated FROM stats_mysql_client_host_cache ORDER BY error_count DESC, last_updated DESC LIMIT %d"
+	mySQLClientHostCacheSummaryQuery = "SELECT COUNT(*), COALESCE(SUM(CASE WHEN error_count >= %d THEN 1 ELSE 0 END), 0) FROM stats_mysql_client_host_cache"
+	mySQLClientHostErrorCountsQuery  = "SELECT variable_value FROM runtime_global_variables WHERE variable_name = 'mysql-client_host_error_counts'"
+)
+
+var (
+	mySQLClientHostCacheErrorsDesc = newDesc("client_host_cache", "error_count",
+		"Number of connection errors from client address since its last successful connection.",
+		[]string{"client_address"})
+	mySQLClientHostCacheLastUpdatedDesc = newDesc("client_host_cache", "last_updated_us",
+		"Time of the last connection error from client address, in microseconds as reported by ProxySQL.",
+		[]string{"client_address"})
+	mySQLClientHostCacheEntriesDesc = newDesc("client_host_cache", "entries",
+		"Number of client addresses in stats_mysql_client_host_cache.", nil)
+	mySQLClientHostCacheBlockedDesc = newDesc("client_host_cache", "blocked_hosts",
+		"Number of client addresses currently blocked because they reached mysql-client_host_error_counts errors.", nil)
+)
+
+// scrapeMySQLClientHostCache collects per-client connection errors from `stats_mysql_client_host_cache`
+// for at most limit addresses with the most errors, and the number of blocked addresses.
+func scrapeMySQLClientHostCache(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, limit int) error {
+	rows, err := db.QueryContext(ctx, fmt.Sprintf(mySQLClientHostCacheQuery, limit))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var address string
+		var errorCount, lastUpdated float64
+		if err = rows.Scan(&address, &errorCount, &lastUpdated); err != nil {
+			return err
+		}
+		ch <- prometheus.MustNewConstMetric(mySQLClientHostCacheErrorsDesc, prometheus.GaugeValue, errorCount, address)
+		ch <- prometheus.MustNewConstMetric(mySQLClientHostCacheLastUpdatedDesc, prometheus.GaugeValue, lastUpdated, address)
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// the variable is not readable without admin credentials, blocked_hosts is not collected then
+	var threshold int
+	thresholdKnown := true
+	err = db.QueryRowContext(ctx, mySQLClientHostErrorCountsQuery).Scan(&threshold)
+	switch {
+	case err == nil:
+	case errors.Is(err, sql.ErrNoRows) || isPermissionError(err):
+		logger.Debug("Error reading mysql-client_host_error_counts", "error", err)
+		thresholdKnown = false
+	default:
+		return err
+	}
+
+	var entries, blocked float64
+	if err = db.QueryRowContext(ctx, fmt.Sprintf(mySQLClientHostCacheSummaryQuery, threshold)).Scan(&entries, &blocked); err != nil {
+		return err
+	}
+	ch <- prometheus.MustNewConstMetric(mySQLClientHostCacheEntriesDesc, prometheus.GaugeValue, entries)
+	if thresholdKnown {
+		// 0 disables blocking
+		if threshold <= 0 {
+			blocked = 0
+		}
+		ch <- prometheus.MustNewConstMetric(mySQLClientHostCacheBlockedDesc, prometheus.GaugeValue, blocked)
+	}
+	return nil
+}
+
+const mySQLFreeConnectionsQuery = "SELECT hostgroup, srv_host, srv_port, statistics FROM stats_mysql_free_connections"
+
+// key - statistics field name; values are summed over free connections of the endpoint.
+var mySQLFreeConnectionsMetrics = map[string]*metric{
+	"count": {"count", prometheus.GaugeValue,
+		"Number of free connections in the connection pool."},
+	"questions": {"questions", prometheus.GaugeValue,
+		"Number of queries sent over free connections since they were created."},
+	"bytes_sent": {"bytes_sent", prometheus.GaugeValue,
+		"Amount of data sent to the backend over free connections since they were created."},
+	"bytes_recv": {"bytes_recv", prometheus.GaugeValue,
+		"Amount of data received from the backend over free connections since they were created."},
+	"myconnpoll_get": {"myconnpoll_get", prometheus.GaugeValue,
+		"Number of times free connections were taken from the connection pool."},
+	"myconnpoll_put": {"myconnpoll_put", prometheus.GaugeValue,
+		"Number of times free connections were returned to the connection pool."},
+}
+
+var (
+	mySQLFreeConnectionsDescs = newMetricDescs("free_connections", []string{"hostgroup", "endpoint"}, mySQLFreeConnectionsMetrics,
+		"Undocumented stats_mysql_free_connections statistics field, summed over free connections.")
+	mySQLFreeConnectionsAgeDesc = newDesc("free_connections", "age_milliseconds",
+		"Histogram over age in ms of free connections.",
+		[]string{"hostgroup", "endpoint"})
+)
+
+// mySQLFreeConnectionsAgeBuckets are upper bounds in ms of age_milliseconds histogram buckets.
+var mySQLFreeConnectionsAgeBuckets = []float64{1000, 10000, 60000, 300000, 900000, 3600000, 14400000, 86400000}
+
+// freeConnectionsEndpoint accumulates stats_mysql_free_connections rows of a single endpoint.
+type freeConnectionsEndpoint struct {
+	count      uint64
+	ageCount   uint64
+	ageSum     float64
+	ageBuckets map[float64]uint64
+	statistics map[string]float64
+}
+
+// scrapeMySQLFreeConnections collects free connections summary per hostgroup and endpoint
+// from `stats_mysql_free_connections`.
+func scrapeMySQLFreeConnections(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, mySQLFreeConnectionsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	endpoints := make(map[[2]string]*freeConnectionsEndpoint)
+	for rows.Next() {
+		var hostgroup, srvHost, srvPort string
+		var statistics sql.NullString
+		if err = rows.Scan(&hostgroup, &srvHost, &srvPort, &statistics); err != nil {
+			return err
+		}
+
+		key := [2]string{hostgroup, srvHost + ":" + srvPort}
+		e := endpoints[key]
+		if e == nil {
+			e = &freeConnectionsEndpoint{
+				ageBuckets: make(map[float64]uint64, len(mySQLFreeConnectionsAgeBuckets)),
+				statistics: make(map[string]float64),
+			}
+			endpoints[key] = e
+		}
+		e.count++
+
+		// statistics is a JSON object like {"address":"0x7f...","age_ms":5006,"bytes_recv":21,"questions":1,...}
+		var fields map[string]interface{}
+		if err := json.Unmarshal([]byte(statistics.String), &fields); err != nil {
+			logger.Debug("Failed to parse stats_mysql_free_connections statistics", "statistics", statistics.String, "error", err)
+			continue
+		}
+		for field, v := range fields {
+			value, ok := v.(float64)
+			if !ok {
+				continue
+			}
+			field = strings.ToLower(field)
+			if field != "age_ms" {
+				e.statistics[field] += value
+				continue
+			}
+			e.ageCount++
+			e.ageSum += value
+			for _, bound := range mySQLFreeConnectionsAgeBuckets {
+				if value <= bound {
+					e.ageBuckets[bound]++
+				}
+			}
+		}
+	}
+	if err = rows.Err(); err != nil {
+		return err
+	}
+
+	// sort for stable output
+	keys := make([][2]string, 0, len(endpoints))
+	for key := range endpoints {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b [2]string) int { return slices.Compare(a[:], b[:]) })
+
+	for _, key := range keys {
+		e := endpoints[key]
+
+		m, desc := mySQLFreeConnectionsDescs.get("count")
+		ch <- prometheus.MustNewConstMetric(desc, m.valueType, float64(e.count), key[0], key[1])
+		ch <- prometheus.MustNewConstHistogram(mySQLFreeConnectionsAgeDesc, e.ageCount, e.ageSum, e.ageBuckets, key[0], key[1])
+
+		fields := make([]string, 0, len(e.statistics))
+		for field := range e.statistics {
+			fields = append(fields, field)
+		}
+		slices.Sort(fields)
+		for _, field := range fields {
+			valueType, desc := mySQLFreeConnectionsDescs.getOrUndocumented(field, field)
+			ch <- prometheus.MustNewConstMetric(desc, valueType, e.statistics[field], key[0], key[1])
+		}
+	}
+	return nil
+}
+
+const mySQLPreparedStatementsQuery = `
+    SELECT
+        hostgroup, schemaname, username,
+        COUNT(*) AS count, SUM(ref_count_client) AS ref_count_client, SUM(ref_count_server) AS ref_count_server
+    FROM
+        stats_mysql_prepared_statements_info
+    GROUP BY hostgroup, schemaname, username
+`
+
+var mySQLPreparedStatementsMetrics = map[string]*metric{
+	"count": {"count", prometheus.GaugeValue,
+		"Number of prepared statements in the global cache."},
+	"ref_count_client": {"ref_count_client", prometheus.GaugeValue,
+		"Number of references to prepared statements from client connections."},
+	"ref_count_server": {"ref_count_server", prometheus.GaugeValue,
+		"Number of references to prepared statements from backend connections."},
+}
+
+// stats_mysql_prepared_statements_info has no backend endpoint, only hostgroup.
+var mySQLPreparedStatementsDescs = newMetricDescs("prepared_statements", []string{"hostgroup", "schemaname", "username"},
+	mySQLPreparedStatementsMetrics, "")
+
+// scrapeMySQLPreparedStatements collects prepared statements counts per hostgroup, schema and user
+// from `stats_mysql_prepared_statements_info`.
+func scrapeMySQLPreparedStatements(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, mySQLPreparedStatementsQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hostgroup, schemaname, username string
+		var count, refCountClient, refCountServer float64
+		if err = rows.Scan(&hostgroup, &schemaname, &username, &count, &refCountClient, &refCountServer); err != nil {
+			return err
+		}
+
+		values := []float64{count, refCountClient, refCountServer}
+		for i, key := range []string{"count", "ref_count_client", "ref_count_server"} {
+			m, desc := mySQLPreparedStatementsDescs.get(key)
+			ch <- prometheus.MustNewConstMetric(desc, m.valueType, values[i], hostgroup, schemaname, username)
+		}
+	}
+	return rows.Err()
+}
+
+const globalVariablesQuery = "SELECT variable_name, variable_value FROM runtime_global_variables"
+
+var globalVariableDesc = newDesc("", "global_variable",
+	"Value of numeric or boolean (1 - true, 0 - false) ProxySQL global variable from runtime_global_variables.",
+	[]string{"name"})
+
+// defaultGlobalVariables are variables collected from runtime_global_variables by default.
+var defaultGlobalVariables = []string{
+	"mysql-client_host_cache_size",
+	"mysql-client_host_error_counts",
+	"mysql-connect_timeout_server",
+	"mysql-default_query_timeout",
+	"mysql-free_connections_pct",
+	"mysql-long_query_time",
+	"mysql-max_connections",
+	"mysql-max_transaction_time",
+	"mysql-monitor_connect_interval",
+	"mysql-monitor_enabled",
+	"mysql-monitor_ping_interval",
+	"mysql-monitor_read_only_interval",
+	"mysql-monitor_replication_lag_interval",
+	"mysql-query_digests",
+	"mysql-shun_on_failures",
+	"mysql-threads",
+}
+
+// secretGlobalVariableRE matches variables which are never collected, even if selected.
+var secretGlobalVariableRE = regexp.MustCompile(`(?i)password|credentials|secret`)
+
+// globalVariablesFilter selects variables collected from runtime_global_variables.
+// A nil filter selects default variables.
+type globalVariablesFilter struct {
+	all   bool
+	names map[string]bool
+	match *regexp.Regexp // if not nil, matching variables are collected in addition to names
+}
+
+// newGlobalVariablesFilter returns filter for comma-separated list of variables and regular expression.
+// List "all" selects all variables.
+func newGlobalVariablesFilter(names, match string) (*globalVariablesFilter, error) {
+	f := &globalVariablesFilter{
+		names: make(map[string]bool),
+	}
+	for _, n := range strings.Split(names, ",") {
+		n = strings.ToLower(strings.TrimSpace(n))
+		if n == "all" {
+			f.all = true
+			break
+		}
+		if n != "" {
+			f.names[n] = true
+		}
+	}
+	if match != "" {
+		re, err := regexp.Compile(match)
+		if err != nil {
+			return nil, fmt.Errorf("invalid global variables regex %q: %w", match, err)
+		}
+		f.match = re
+	}
+	return f, nil
+}
+
+// selected returns true if variable should be collected. Password-like variables are never selected.
+func (f *globalVariablesFilter) selected(name string) bool {
+	name = strings.ToLower(name)
+	if secretGlobalVariableRE.MatchString(name) {
+		return false
+	}
+	if f == nil {
+		return slices.Contains(defaultGlobalVariables, name)
+	}
+	return f.all || f.names[name] || (f.match != nil && f.match.MatchString(name))
+}
+
+// scrapeGlobalVariables collects numeric and boolean variables selected by filter from `runtime_global_variables`.
+func scrapeGlobalVariables(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, filter *globalVariablesFilter) error {
+	rows, err := db.QueryContext(ctx, globalVariablesQuery)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var name string
+		var valueS sql.NullString
+		if err = rows.Scan(&name, &valueS); err != nil {
+			return err
+		}
+		if !filter.selected(name) || !valueS.Valid {
+			continue
+		}
+
+		var value float64
+		switch strings.ToLower(valueS.String) {
+		case "true":
+			value = 1
+		case "false":
+			value = 0
+		default:
+			value, err = strconv.ParseFloat(valueS.String, 64)
+			if err != nil {
+				logger.Debug(fmt.Sprintf("variable %s: %s", name, err))
+				continue
+			}
+		}
+		ch <- prometheus.MustNewConstMetric(globalVariableDesc, prometheus.GaugeValue, value, strings.ToLower(name))
+	}
+	return rows.Err()
+}
+
+const proxySQLVersionQuery = "select variable_value from global_variables where variable_name = 'admin-version'"
+
+var proxySQLInfoDesc = newDesc("", "info", "ProxySQL info", []string{"version"})
 
-func scrapeProxySQLInfo(db *sql.DB, ch chan<- prometheus.Metric) error {
-	rows, err := db.Query(proxySQLVersionQuery)
+func scrapeProxySQLInfo(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	rows, err := db.QueryContext(ctx, proxySQLVersionQuery)
 	if err != nil {
 		return err
 	}
@@ -801,11 +2567,7 @@ func scrapeProxySQLInfo(db *sql.DB, ch chan<- prometheus.Metric) error {
 			return err
 		}
 		ch <- prometheus.MustNewConstMetric(
-			prometheus.NewDesc(
-				prometheus.BuildFQName(namespace, "", "info"),
-				"ProxySQL info",
-				[]string{"version"}, nil,
-			),
+			proxySQLInfoDesc,
 			prometheus.GaugeValue,
 			0,
 			version,
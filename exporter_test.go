@@ -15,17 +15,21 @@
 package main
 
 import (
+	"context"
 	"errors"
+	"fmt"
 	"math"
 	"regexp"
 	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-sql-driver/mysql"
 	"github.com/prometheus/client_golang/prometheus"
 	dto "github.com/prometheus/client_model/go"
 	"github.com/smartystreets/goconvey/convey"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"gopkg.in/DATA-DOG/go-sqlmock.v1"
 )
 
@@ -76,6 +80,39 @@ func readMetric(m prometheus.Metric) *metricResult {
 	panic("Unsupported metric type")
 }
 
+// histogramResult contains sample count, sum and cumulative counts of selected buckets by upper bound of histogram.
+type histogramResult struct {
+	count   uint64
+	sum     float64
+	buckets map[float64]uint64
+}
+
+// readHistogram returns histogramResult of histogram metric with buckets of given upper bounds;
+// missing buckets have zero counts.
+func readHistogram(m prometheus.Metric, upperBounds ...float64) histogramResult {
+	var pb dto.Metric
+	if err := m.Write(&pb); err != nil {
+		panic(err)
+	}
+
+	res := histogramResult{
+		count:   pb.GetHistogram().GetSampleCount(),
+		sum:     pb.GetHistogram().GetSampleSum(),
+		buckets: make(map[float64]uint64, len(upperBounds)),
+	}
+	for _, u := range upperBounds {
+		res.buckets[u] = 0
+	}
+	for _, b := range pb.GetHistogram().GetBucket() {
+		for _, u := range upperBounds {
+			if b.GetUpperBound() == u {
+				res.buckets[u] = b.GetCumulativeCount()
+			}
+		}
+	}
+	return res
+}
+
 func sanitizeQuery(q string) string {
 	q = strings.Join(strings.Fields(q), " ")
 	q = strings.Replace(q, "(", "\\(", -1)
@@ -110,7 +147,7 @@ func TestScrapeMySQLGlobal(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLGlobal(db, ch); err != nil {
+		if err = scrapeMySQLGlobal(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -148,7 +185,7 @@ func TestScrapeMySQLGlobalError(t *testing.T) {
 	ch1 := make(chan prometheus.Metric)
 
 	go func() {
-		scrapeMySQLGlobal(db1, ch1)
+		scrapeMySQLGlobal(context.Background(), db1, ch1)
 		close(ch1)
 	}()
 
@@ -164,7 +201,7 @@ func TestScrapeMySQLGlobalError(t *testing.T) {
 
 	ch2 := make(chan prometheus.Metric)
 	go func() {
-		scrapeMySQLGlobal(db2, ch2)
+		scrapeMySQLGlobal(context.Background(), db2, ch2)
 		close(ch2)
 	}()
 
@@ -186,7 +223,7 @@ func TestScrapeMySQLCommandCounter(t *testing.T) { //nolint:funlen
 	ch := make(chan prometheus.Metric)
 
 	go func() {
-		if err = scrapeMySQLCommandCounterMetrics(db, ch); err != nil {
+		if err = scrapeMySQLCommandCounterMetrics(context.Background(), db, ch, nil); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -263,7 +300,7 @@ func TestScrapeMySQLConnectionPool(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLConnectionPool(db, ch); err != nil {
+		if err = scrapeMySQLConnectionPool(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -334,7 +371,7 @@ func TestScrapeMySQLConnectionPoolError(t *testing.T) {
 	ch1 := make(chan prometheus.Metric)
 
 	go func() {
-		scrapeMySQLConnectionPool(db1, ch1)
+		scrapeMySQLConnectionPool(context.Background(), db1, ch1)
 		close(ch1)
 	}()
 
@@ -359,7 +396,7 @@ func TestScrapeMySQLConnectionPoolError(t *testing.T) {
 
 	ch2 := make(chan prometheus.Metric)
 	go func() {
-		scrapeMySQLConnectionPool(db2, ch2)
+		scrapeMySQLConnectionPool(context.Background(), db2, ch2)
 		close(ch2)
 	}()
 
@@ -391,7 +428,7 @@ func TestScrapeMySQLRuntimeServers(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLRuntimeServers(db, ch); err != nil {
+		if err = scrapeMySQLRuntimeServers(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -454,7 +491,7 @@ func TestScrapeMySQLRuntimeServersError(t *testing.T) {
 	ch1 := make(chan prometheus.Metric)
 
 	go func() {
-		scrapeMySQLRuntimeServers(db1, ch1)
+		scrapeMySQLRuntimeServers(context.Background(), db1, ch1)
 		close(ch1)
 	}()
 
@@ -477,7 +514,7 @@ func TestScrapeMySQLRuntimeServersError(t *testing.T) {
 
 	ch2 := make(chan prometheus.Metric)
 	go func() {
-		scrapeMySQLRuntimeServers(db2, ch2)
+		scrapeMySQLRuntimeServers(context.Background(), db2, ch2)
 		close(ch2)
 	}()
 
@@ -508,7 +545,7 @@ func TestScrapeMySQLConnectionList(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMySQLConnectionList(db, ch); err != nil {
+		if err = scrapeMySQLConnectionList(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -545,7 +582,7 @@ func TestScrapeMySQLConnectionListError(t *testing.T) {
 	ch1 := make(chan prometheus.Metric)
 
 	go func() {
-		scrapeMySQLConnectionList(db1, ch1)
+		scrapeMySQLConnectionList(context.Background(), db1, ch1)
 		close(ch1)
 	}()
 
@@ -565,7 +602,7 @@ func TestScrapeMySQLConnectionListError(t *testing.T) {
 
 	ch2 := make(chan prometheus.Metric)
 	go func() {
-		scrapeMySQLConnectionList(db2, ch2)
+		scrapeMySQLConnectionList(context.Background(), db2, ch2)
 		close(ch2)
 	}()
 
@@ -596,7 +633,7 @@ func TestScrapeDetailedConnectionList(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeDetailedMySQLConnectionList(db, ch); err != nil {
+		if err = scrapeDetailedMySQLConnectionList(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -622,6 +659,57 @@ func TestScrapeDetailedConnectionList(t *testing.T) {
 	}
 }
 
+func TestScrapeMySQLSessions(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "srv_host", "srv_port", "command", "time_ms"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "db-1", "3306", "Query", 120000).
+		AddRow("10", "db-1", "3306", "Query", 50).
+		AddRow("10", "", "", "Sleep", 900000).
+		AddRow("10", "db-2", "3306", "Connect", 5)
+	mock.ExpectQuery(sanitizeQuery(mySQLSessionsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLSessions(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	hg := prometheus.Labels{"hostgroup": "10"}
+	counterExpected := []metricResult{
+		{"proxysql_processlist_sessions", prometheus.Labels{"command": "Connect", "hostgroup": "10", "endpoint": "db-2:3306"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_processlist_sessions", prometheus.Labels{"command": "Query", "hostgroup": "10", "endpoint": "db-1:3306"}, 2, dto.MetricType_GAUGE},
+		{"proxysql_processlist_sessions", prometheus.Labels{"command": "Sleep", "hostgroup": "10", "endpoint": ""}, 1, dto.MetricType_GAUGE},
+		{"proxysql_processlist_active_session_time_milliseconds", hg, 0, dto.MetricType_HISTOGRAM},
+		{"proxysql_processlist_oldest_query_time_milliseconds", hg, 120000, dto.MetricType_GAUGE},
+	}
+	// sleeping sessions are not active
+	histogramExpected := histogramResult{3, 120055, map[float64]uint64{10: 1, 100: 2, 60000: 2, 300000: 3}}
+
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			m := <-ch
+			got := *readMetric(m)
+			cv.So(got, convey.ShouldResemble, expect)
+			if got.metricType == dto.MetricType_HISTOGRAM {
+				cv.So(readHistogram(m, 10, 100, 60000, 300000), convey.ShouldResemble, histogramExpected)
+			}
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
 func TestScrapeMemoryMetrics(t *testing.T) {
 	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
 		for c, m := range memoryMetricsMetrics {
@@ -644,7 +732,7 @@ func TestScrapeMemoryMetrics(t *testing.T) {
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeMemoryMetrics(db, ch); err != nil {
+		if err = scrapeMemoryMetrics(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -684,7 +772,7 @@ func TestScrapeProxySQLInfo(t *testing.T) { //nolint:paralleltest
 
 	ch := make(chan prometheus.Metric)
 	go func() {
-		if err = scrapeProxySQLInfo(db, ch); err != nil {
+		if err = scrapeProxySQLInfo(context.Background(), db, ch); err != nil {
 			t.Errorf("error calling function on test: %s", err)
 		}
 		close(ch)
@@ -715,7 +803,7 @@ func TestScrapeMemoryMetricsError(t *testing.T) {
 	mock.ExpectQuery(sanitizeQuery(memoryMetricsQuery)).WillReturnError(errors.New("error"))
 
 	ch := make(chan prometheus.Metric)
-	err = scrapeMemoryMetrics(db, ch)
+	err = scrapeMemoryMetrics(context.Background(), db, ch)
 	assert.Error(t, err)
 }
 
@@ -731,20 +819,1115 @@ func TestScrapeDetailedConnectionLisError(t *testing.T) {
 
 		ch := make(chan prometheus.Metric)
 
-		err = scrapeDetailedMySQLConnectionList(db, ch)
+		err = scrapeDetailedMySQLConnectionList(context.Background(), db, ch)
 		assert.Error(t, err)
 	})
 }
 
-func TestExporter(t *testing.T) {
-	if testing.Short() {
-		t.Skip("-short is passed, skipping integration test")
+func TestScrapeMySQLQueryDigest(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLQueryDigestMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
 	}
+	defer db.Close()
 
-	// wait up to 30 seconds for ProxySQL to become available
-	exporter := NewExporter("admin:admin@tcp(127.0.0.1:16032)/", true, true, true, true, true, true, true)
+	columns := []string{"hostgroup", "schemaname", "username", "digest", "count_star", "sum_time", "min_time", "max_time",
+		"sum_rows_affected", "sum_rows_sent"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("1", "sbtest", "app", "0x3BFB2DAD8A5A5A4E", "1200", "58000", "20", "900", "0", "1200").
+		AddRow("2", "sbtest", "app", "0x5DE0C47D1E1C4B04", "300", "12000", "15", "400", "300", nil)
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLQueryDigestQuery, "", "sum_time", 2))).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLQueryDigest(context.Background(), db, ch, 2, "sum_time", nil, nil); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels1 := prometheus.Labels{"hostgroup": "1", "schemaname": "sbtest", "username": "app", "digest": "0x3BFB2DAD8A5A5A4E"}
+	labels2 := prometheus.Labels{"hostgroup": "2", "schemaname": "sbtest", "username": "app", "digest": "0x5DE0C47D1E1C4B04"}
+	counterExpected := []metricResult{
+		{"proxysql_query_digest_count_star", labels1, 1200, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_sum_time_us", labels1, 58000, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_min_time_us", labels1, 20, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_max_time_us", labels1, 900, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_sum_rows_affected", labels1, 0, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_sum_rows_sent", labels1, 1200, dto.MetricType_COUNTER},
+
+		{"proxysql_query_digest_count_star", labels2, 300, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_sum_time_us", labels2, 12000, dto.MetricType_COUNTER},
+		{"proxysql_query_digest_min_time_us", labels2, 15, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_max_time_us", labels2, 400, dto.MetricType_GAUGE},
+		{"proxysql_query_digest_sum_rows_affected", labels2, 300, dto.MetricType_COUNTER},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLQueryDigestError(t *testing.T) {
+	db, _, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	ch := make(chan prometheus.Metric)
+	assert.Error(t, scrapeMySQLQueryDigest(context.Background(), db, ch, 10, "digest_text", nil, nil))
+	assert.Error(t, scrapeMySQLQueryDigest(context.Background(), db, ch, 0, "sum_time", nil, nil))
+}
+
+func TestMySQLQueryDigestWhere(t *testing.T) {
+	assert.Equal(t, "", mySQLQueryDigestWhere(nil, nil))
+	assert.Equal(t, "WHERE schemaname IN ('sbtest', 'o''brien')", mySQLQueryDigestWhere([]string{"sbtest", "o'brien"}, nil))
+	assert.Equal(t, "WHERE schemaname IN ('sbtest') AND username IN ('app')", mySQLQueryDigestWhere([]string{"sbtest"}, []string{"app"}))
+}
+
+func TestScrapeMySQLQueryRules(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	runtimeRows := sqlmock.NewRows([]string{"rule_id", "destination_hostgroup", "match_digest", "match_pattern", "comment"}).
+		AddRow("1", "2", "^SELECT.*FOR UPDATE$", nil, "writes").
+		AddRow("2", "3", nil, "^SELECT", nil)
+	mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesRuntimeQuery)).WillReturnRows(runtimeRows)
+	statsRows := sqlmock.NewRows([]string{"rule_id", "hits"}).
+		AddRow("1", "42").
+		AddRow("2", "1337")
+	mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesStatsQuery)).WillReturnRows(statsRows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLQueryRules(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_query_rules_hits_total", prometheus.Labels{"rule_id": "1", "destination_hostgroup": "2",
+			"match_digest": "^SELECT.*FOR UPDATE$", "match_pattern": "", "comment": "writes"}, 42, dto.MetricType_COUNTER},
+		{"proxysql_query_rules_hits_total", prometheus.Labels{"rule_id": "2", "destination_hostgroup": "3",
+			"match_digest": "", "match_pattern": "^SELECT", "comment": ""}, 1337, dto.MetricType_COUNTER},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLQueryRulesError(t *testing.T) {
+	t.Run("permission error on runtime table", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening a stub database connection: %s", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesRuntimeQuery)).WillReturnError(&mysql.MySQLError{Number: 1045})
+		statsRows := sqlmock.NewRows([]string{"rule_id", "hits"}).AddRow("1", "42")
+		mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesStatsQuery)).WillReturnRows(statsRows)
+
+		ch := make(chan prometheus.Metric)
+		go func() {
+			if err = scrapeMySQLQueryRules(context.Background(), db, ch); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+			close(ch)
+		}()
+
+		got := *readMetric(<-ch)
+		assert.Equal(t, metricResult{"proxysql_query_rules_hits_total", prometheus.Labels{"rule_id": "1", "destination_hostgroup": "",
+			"match_digest": "", "match_pattern": "", "comment": ""}, 42, dto.MetricType_COUNTER}, got)
+	})
+
+	t.Run("error on sql query", func(t *testing.T) {
+		db, mock, err := sqlmock.New()
+		if err != nil {
+			t.Fatalf("error opening a stub database connection: %s", err)
+		}
+		defer db.Close()
+
+		mock.ExpectQuery(sanitizeQuery(mySQLQueryRulesRuntimeQuery)).WillReturnError(errors.New("error"))
+
+		ch := make(chan prometheus.Metric)
+		err = scrapeMySQLQueryRules(context.Background(), db, ch)
+		assert.Error(t, err)
+	})
+}
+
+func TestScrapeMySQLErrors(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "hostname", "port", "username", "schemaname", "errno", "count_star", "first_seen", "last_seen", "last_error"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("1", "10.91.142.80", "3306", "app", "sbtest", "1040", "10", "1500000000", "1500000100", "Too many connections").
+		AddRow("1", "10.91.142.80", "3306", "app", "sbtest", "1062", "2", "1500000050", "1500000060", "Duplicate entry '5' for key 'PRIMARY'").
+		AddRow("1", "10.91.142.80", "3306", "app", "sbtest", "1062", "3", "1500000010", "1500000070", "Duplicate entry '7' for key 'PRIMARY'")
+	mock.ExpectQuery(sanitizeQuery(mySQLErrorsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLErrors(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels1 := prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.80:3306", "username": "app", "schemaname": "sbtest",
+		"errno": "1040", "last_error": "Too many connections"}
+	labels2 := prometheus.Labels{"hostgroup": "1", "endpoint": "10.91.142.80:3306", "username": "app", "schemaname": "sbtest",
+		"errno": "1062", "last_error": "Duplicate entry ? for key ?"}
+	counterExpected := []metricResult{
+		{"proxysql_mysql_errors_total", labels1, 10, dto.MetricType_COUNTER},
+		{"proxysql_mysql_errors_first_seen_timestamp_seconds", labels1, 1500000000, dto.MetricType_GAUGE},
+		{"proxysql_mysql_errors_last_seen_timestamp_seconds", labels1, 1500000100, dto.MetricType_GAUGE},
+		{"proxysql_mysql_errors_total", labels2, 5, dto.MetricType_COUNTER},
+		{"proxysql_mysql_errors_first_seen_timestamp_seconds", labels2, 1500000010, dto.MetricType_GAUGE},
+		{"proxysql_mysql_errors_last_seen_timestamp_seconds", labels2, 1500000070, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestNormalizeMySQLError(t *testing.T) {
+	assert.Equal(t, "Access denied for user ?@? (using password: YES)",
+		normalizeMySQLError("Access denied for user 'app'@'10.0.0.1' (using password: YES)"))
+	assert.Equal(t, "Got a packet bigger than ? bytes", normalizeMySQLError("Got a packet bigger than  4194304 bytes"))
+	assert.Len(t, normalizeMySQLError(strings.Repeat("x", 1000)), mySQLErrorsLastErrorMaxLength)
+}
+
+func TestScrapeMonitor(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostname", "port", "MAX(time_start_us)", "value", "error"}
+	mock.ExpectQuery(sanitizeQuery(monitorLogs[0].query)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "3306", "1500000000000000", "163", nil).
+		AddRow("10.91.142.82", "3306", "1500000001000000", "0", "timeout on creating new connection: Can't connect"))
+	mock.ExpectQuery(sanitizeQuery(monitorLogs[1].query)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "3306", "1500000000000000", "2100", ""))
+	mock.ExpectQuery(sanitizeQuery(monitorLogs[2].query)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "3306", "1500000000000000", nil, "timeout check"))
+	mock.ExpectQuery(sanitizeQuery(monitorLogs[3].query)).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.91.142.80", "3306", "1500000000000000", "12", nil))
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMonitor(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels1 := prometheus.Labels{"endpoint": "10.91.142.80:3306"}
+	labels2 := prometheus.Labels{"endpoint": "10.91.142.82:3306"}
+	counterExpected := []metricResult{
+		{"proxysql_monitor_ping_latency_us", labels1, 163, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_error", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_timestamp_seconds", labels1, 1500000000, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_latency_us", labels2, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_error", labels2, 1, dto.MetricType_GAUGE},
+		{"proxysql_monitor_ping_timestamp_seconds", labels2, 1500000001, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_connect_latency_us", labels1, 2100, dto.MetricType_GAUGE},
+		{"proxysql_monitor_connect_error", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_connect_timestamp_seconds", labels1, 1500000000, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_read_only_error", labels1, 1, dto.MetricType_GAUGE},
+		{"proxysql_monitor_read_only_timestamp_seconds", labels1, 1500000000, dto.MetricType_GAUGE},
+
+		{"proxysql_monitor_replication_lag_seconds", labels1, 12, dto.MetricType_GAUGE},
+		{"proxysql_monitor_replication_lag_error", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_monitor_replication_lag_timestamp_seconds", labels1, 1500000000, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMonitorError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(monitorLogs[0].query)).WillReturnError(&mysql.MySQLError{Number: 1045})
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMonitor(context.Background(), db, ch)
+	assert.True(t, isPermissionError(err))
+}
+
+func TestScrapeGroupReplication(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"writer_hostgroup", "backup_writer_hostgroup", "reader_hostgroup", "offline_hostgroup", "active",
+		"max_writers", "writer_is_also_reader", "max_transactions_behind", "comment"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "20", "30", "40", "1", "1", "0", "100", "gr cluster")
+	mock.ExpectQuery("SELECT \\* FROM runtime_mysql_group_replication_hostgroups").WillReturnRows(rows)
+
+	logColumns := []string{"endpoint", "MAX(time_start_us)", "viable_candidate", "read_only", "transactions_behind"}
+	logRows := sqlmock.NewRows(logColumns).
+		AddRow("10.91.142.80:3306", "1500000000000000", "YES", "NO", "0").
+		AddRow("10.91.142.82:3306", "1500000000000000", "NO", "YES", nil)
+	mock.ExpectQuery(sanitizeQuery(groupReplicationLog.query)).WillReturnRows(logRows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeGroupReplication(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	writer := prometheus.Labels{"writer_hostgroup": "10"}
+	labels1 := prometheus.Labels{"endpoint": "10.91.142.80:3306"}
+	labels2 := prometheus.Labels{"endpoint": "10.91.142.82:3306"}
+	counterExpected := []metricResult{
+		{"proxysql_group_replication_hostgroups_info", prometheus.Labels{"writer_hostgroup": "10", "backup_writer_hostgroup": "20",
+			"reader_hostgroup": "30", "offline_hostgroup": "40"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_hostgroups_active", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_hostgroups_max_writers", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_hostgroups_writer_is_also_reader", writer, 0, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_hostgroups_max_transactions_behind", writer, 100, dto.MetricType_GAUGE},
+
+		{"proxysql_group_replication_viable_candidate", labels1, 1, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_read_only", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_transactions_behind", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_viable_candidate", labels2, 0, dto.MetricType_GAUGE},
+		{"proxysql_group_replication_read_only", labels2, 1, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeGalera(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"writer_hostgroup", "backup_writer_hostgroup", "reader_hostgroup", "offline_hostgroup", "active",
+		"max_writers", "writer_is_also_reader", "max_transactions_behind", "comment"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "20", "30", "40", "1", "1", "2", nil, "pxc cluster")
+	mock.ExpectQuery("SELECT \\* FROM runtime_mysql_galera_hostgroups").WillReturnRows(rows)
+
+	logColumns := []string{"endpoint", "MAX(time_start_us)", "primary_partition", "read_only", "wsrep_local_recv_queue",
+		"wsrep_local_state", "wsrep_desync", "wsrep_reject_queries", "wsrep_sst_donor_rejects_queries", "pxc_maint_mode"}
+	logRows := sqlmock.NewRows(logColumns).
+		AddRow("10.91.142.80:3306", "1500000000000000", "YES", "NO", "0", "4", "NO", "NO", "YES", "NO").
+		// the most recent check failed
+		AddRow("10.91.142.81:3306", "1500000000000000", nil, nil, nil, nil, nil, nil, nil, nil).
+		AddRow("10.91.142.82:3306", "1500000000000000", "no", "yes", "3", "2", "YES", "NO", "NO", "YES")
+	mock.ExpectQuery(sanitizeQuery(galeraLog.query)).WillReturnRows(logRows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeGalera(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	writer := prometheus.Labels{"writer_hostgroup": "10"}
+	labels1 := prometheus.Labels{"endpoint": "10.91.142.80:3306"}
+	labels2 := prometheus.Labels{"endpoint": "10.91.142.82:3306"}
+	counterExpected := []metricResult{
+		{"proxysql_galera_hostgroups_info", prometheus.Labels{"writer_hostgroup": "10", "backup_writer_hostgroup": "20",
+			"reader_hostgroup": "30", "offline_hostgroup": "40"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_hostgroups_active", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_hostgroups_max_writers", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_hostgroups_writer_is_also_reader", writer, 2, dto.MetricType_GAUGE},
+
+		{"proxysql_galera_primary_partition", labels1, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_read_only", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_local_recv_queue", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_local_state", labels1, 4, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_desync", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_reject_queries", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_sst_donor_rejects_queries", labels1, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_pxc_maint_mode", labels1, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_primary_partition", labels2, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_read_only", labels2, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_local_recv_queue", labels2, 3, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_local_state", labels2, 2, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_desync", labels2, 1, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_reject_queries", labels2, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_wsrep_sst_donor_rejects_queries", labels2, 0, dto.MetricType_GAUGE},
+		{"proxysql_galera_pxc_maint_mode", labels2, 1, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeAWSAuroraHostgroups(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"writer_hostgroup", "reader_hostgroup", "active", "aurora_port", "domain_name", "max_lag_ms",
+		"check_interval_ms", "check_timeout_ms", "writer_is_also_reader", "new_reader_weight", "add_lag_ms", "min_lag_ms",
+		"lag_num_checks", "comment"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "20", "1", "3306", ".abcdefghijkl.us-east-1.rds.amazonaws.com", "600000", "1000", "800", "0", "1",
+			"30", "30", nil, "aurora cluster")
+	mock.ExpectQuery("SELECT \\* FROM runtime_mysql_aws_aurora_hostgroups").WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeTopologyHostgroups(context.Background(), db, ch, awsAuroraHostgroups); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	writer := prometheus.Labels{"writer_hostgroup": "10"}
+	counterExpected := []metricResult{
+		{"proxysql_aws_aurora_hostgroups_info", prometheus.Labels{"writer_hostgroup": "10", "reader_hostgroup": "20"},
+			1, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_active", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_aurora_port", writer, 3306, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_max_lag_ms", writer, 600000, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_check_interval_ms", writer, 1000, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_check_timeout_ms", writer, 800, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_writer_is_also_reader", writer, 0, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_new_reader_weight", writer, 1, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_add_lag_ms", writer, 30, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_hostgroups_min_lag_ms", writer, 30, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+		_, ok := <-ch
+		cv.So(ok, convey.ShouldBeFalse)
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeAWSAuroraLog(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	logColumns := []string{"server_id", "MAX(time_start_us)", "replica_lag_in_milliseconds", "cpu"}
+	logRows := sqlmock.NewRows(logColumns).
+		AddRow("aurora-node-1", "1500000000000000", "0", "12.5").
+		AddRow("aurora-node-2", "1500000000000000", "17.3", "3")
+	mock.ExpectQuery(sanitizeQuery(awsAuroraLog.query)).WillReturnRows(logRows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeTopologyLog(context.Background(), db, ch, awsAuroraLog); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	counterExpected := []metricResult{
+		{"proxysql_aws_aurora_replica_lag_ms", prometheus.Labels{"server_id": "aurora-node-1"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_cpu", prometheus.Labels{"server_id": "aurora-node-1"}, 12.5, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_replica_lag_ms", prometheus.Labels{"server_id": "aurora-node-2"}, 17.3, dto.MetricType_GAUGE},
+		{"proxysql_aws_aurora_cpu", prometheus.Labels{"server_id": "aurora-node-2"}, 3, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeProxySQLCluster(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(proxySQLClusterLocalChecksumsQuery)).WillReturnRows(sqlmock.NewRows([]string{"name", "checksum"}).
+		AddRow("mysql_servers", "0x7E8B3E38AD8F9F1E").
+		AddRow("mysql_users", "0x0BA6AD0CFE3E6F8F"))
+	mock.ExpectQuery(sanitizeQuery(proxySQLClusterChecksumsQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"hostname", "port", "name", "version", "epoch", "checksum", "diff_check"}).
+			AddRow("proxysql-2", "6032", "mysql_servers", "3", "1500000000", "0x7E8B3E38AD8F9F1E", "0").
+			AddRow("proxysql-2", "6032", "mysql_users", "2", "1500000100", "0x1111111111111111", "5"))
+	mock.ExpectQuery(sanitizeQuery(proxySQLClusterMetricsQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"hostname", "port", "hostname", "port", "weight", "comment", "response_time_ms", "Uptime_s"}).
+			AddRow("proxysql-2", "6032", "proxysql-2", "6032", "0", "peer", "1", "3600"))
+	mock.ExpectQuery(sanitizeQuery(proxySQLClusterStatusQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"hostname", "port", "hostname", "port", "checks_OK"}).
+			AddRow("proxysql-2", "6032", "proxysql-2", "6032", "10"))
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeProxySQLCluster(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	servers := prometheus.Labels{"endpoint": "proxysql-2:6032", "module": "mysql_servers"}
+	users := prometheus.Labels{"endpoint": "proxysql-2:6032", "module": "mysql_users"}
+	peer := prometheus.Labels{"endpoint": "proxysql-2:6032"}
+	counterExpected := []metricResult{
+		{"proxysql_cluster_checksum_version", servers, 3, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_epoch", servers, 1500000000, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_diff_check", servers, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_matches", servers, 1, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_version", users, 2, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_epoch", users, 1500000100, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_diff_check", users, 5, dto.MetricType_GAUGE},
+		{"proxysql_cluster_checksum_matches", users, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_config_in_sync", prometheus.Labels{}, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_peer_weight", peer, 0, dto.MetricType_GAUGE},
+		{"proxysql_cluster_peer_response_time_ms", peer, 1, dto.MetricType_GAUGE},
+		{"proxysql_cluster_peer_uptime_seconds", peer, 3600, dto.MetricType_COUNTER},
+		{"proxysql_cluster_peer_status_checks_ok", peer, 10, dto.MetricType_COUNTER},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLUsers(t *testing.T) {
+	convey.Convey("Metrics are lowercase", t, convey.FailureContinues, func(cv convey.C) {
+		for c, m := range mySQLUsersMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+		for c, m := range mySQLRuntimeUsersMetrics {
+			cv.So(c, convey.ShouldEqual, strings.ToLower(c))
+			cv.So(m.name, convey.ShouldEqual, strings.ToLower(m.name))
+		}
+	})
+
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLUsersQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"username", "username", "frontend_connections", "frontend_max_connections", "backend_connections"}).
+			AddRow("app", "app", "10", "100", "7"))
+	mock.ExpectQuery(sanitizeQuery(mySQLRuntimeUsersQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"username", "default_hostgroup", "transaction_persistent", "active"}).
+			AddRow("app", "1", "1", "1"))
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLUsers(context.Background(), db, ch, true); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels := prometheus.Labels{"username": "app"}
+	counterExpected := []metricResult{
+		{"proxysql_mysql_users_frontend_connections", labels, 10, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_frontend_max_connections", labels, 100, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_backend_connections", labels, 7, dto.MetricType_UNTYPED},
+		{"proxysql_mysql_users_default_hostgroup", labels, 1, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_transaction_persistent", labels, 1, dto.MetricType_GAUGE},
+		{"proxysql_mysql_users_active", labels, 1, dto.MetricType_GAUGE},
+	}
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLUsersError(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(sanitizeQuery(mySQLUsersQuery)).WillReturnRows(sqlmock.NewRows([]string{"username", "username"}))
+	mock.ExpectQuery(sanitizeQuery(mySQLRuntimeUsersQuery)).WillReturnError(&mysql.MySQLError{Number: 1045})
+
+	ch := make(chan prometheus.Metric)
+	err = scrapeMySQLUsers(context.Background(), db, ch, true)
+	assert.NoError(t, err)
+}
+
+func TestScrapeMySQLClientHostCache(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	scrape := func() <-chan prometheus.Metric {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			if err := scrapeMySQLClientHostCache(context.Background(), db, ch, 2); err != nil {
+				t.Errorf("error calling function on test: %s", err)
+			}
+			close(ch)
+		}()
+		return ch
+	}
+
+	columns := []string{"client_address", "error_count", "last_updated"}
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLClientHostCacheQuery, 2))).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow("10.0.0.1", 8, 1700000000000000).
+		AddRow("10.0.0.2", 1, 1600000000000000))
+	mock.ExpectQuery(sanitizeQuery(mySQLClientHostErrorCountsQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"variable_value"}).AddRow("5"))
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLClientHostCacheSummaryQuery, 5))).WillReturnRows(
+		sqlmock.NewRows([]string{"count", "blocked"}).AddRow(3, 1))
+
+	counterExpected := []metricResult{
+		{"proxysql_client_host_cache_error_count", prometheus.Labels{"client_address": "10.0.0.1"}, 8, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_last_updated_us", prometheus.Labels{"client_address": "10.0.0.1"}, 1700000000000000, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_error_count", prometheus.Labels{"client_address": "10.0.0.2"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_last_updated_us", prometheus.Labels{"client_address": "10.0.0.2"}, 1600000000000000, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_entries", prometheus.Labels{}, 3, dto.MetricType_GAUGE},
+		{"proxysql_client_host_cache_blocked_hosts", prometheus.Labels{}, 1, dto.MetricType_GAUGE},
+	}
+
+	ch := scrape()
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+	if m, ok := <-ch; ok {
+		t.Errorf("unexpected metric: %s", m.Desc())
+	}
+
+	// blocked_hosts is not collected without admin credentials
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLClientHostCacheQuery, 2))).WillReturnRows(sqlmock.NewRows(columns))
+	mock.ExpectQuery(sanitizeQuery(mySQLClientHostErrorCountsQuery)).WillReturnError(&mysql.MySQLError{Number: 1045})
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(mySQLClientHostCacheSummaryQuery, 0))).WillReturnRows(
+		sqlmock.NewRows([]string{"count", "blocked"}).AddRow(0, 0))
+
+	counterExpected = []metricResult{
+		{"proxysql_client_host_cache_entries", prometheus.Labels{}, 0, dto.MetricType_GAUGE},
+	}
+
+	ch = scrape()
+	convey.Convey("Metrics comparison without admin credentials", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+	if m, ok := <-ch; ok {
+		t.Errorf("unexpected metric: %s", m.Desc())
+	}
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLFreeConnections(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "srv_host", "srv_port", "statistics"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "db-1", "3306", `{"address":"0x7f1","age_ms":5000,"bytes_recv":100,"bytes_sent":10,"questions":2}`).
+		AddRow("10", "db-1", "3306", `{"address":"0x7f2","age_ms":120000,"bytes_recv":50,"bytes_sent":5,"questions":1}`).
+		AddRow("20", "db-2", "3306", "invalid")
+	mock.ExpectQuery(sanitizeQuery(mySQLFreeConnectionsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLFreeConnections(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	db1 := prometheus.Labels{"hostgroup": "10", "endpoint": "db-1:3306"}
+	db2 := prometheus.Labels{"hostgroup": "20", "endpoint": "db-2:3306"}
+	counterExpected := []metricResult{
+		{"proxysql_free_connections_count", db1, 2, dto.MetricType_GAUGE},
+		{"proxysql_free_connections_age_milliseconds", db1, 0, dto.MetricType_HISTOGRAM},
+		{"proxysql_free_connections_bytes_recv", db1, 150, dto.MetricType_GAUGE},
+		{"proxysql_free_connections_bytes_sent", db1, 15, dto.MetricType_GAUGE},
+		{"proxysql_free_connections_questions", db1, 3, dto.MetricType_GAUGE},
+		{"proxysql_free_connections_count", db2, 1, dto.MetricType_GAUGE},
+		{"proxysql_free_connections_age_milliseconds", db2, 0, dto.MetricType_HISTOGRAM},
+	}
+	// connections with invalid statistics are counted, but not observed
+	histogramExpected := map[string]histogramResult{
+		"db-1:3306": {2, 125000, map[float64]uint64{1000: 0, 10000: 1, 300000: 2}},
+		"db-2:3306": {0, 0, map[float64]uint64{1000: 0, 10000: 0, 300000: 0}},
+	}
+
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			m := <-ch
+			got := *readMetric(m)
+			cv.So(got, convey.ShouldResemble, expect)
+			if got.metricType == dto.MetricType_HISTOGRAM {
+				cv.So(readHistogram(m, 1000, 10000, 300000), convey.ShouldResemble, histogramExpected[got.labels["endpoint"]])
+			}
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLPreparedStatements(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"hostgroup", "schemaname", "username", "count", "ref_count_client", "ref_count_server"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("10", "shop", "app", 12, 30, 8)
+	mock.ExpectQuery(sanitizeQuery(mySQLPreparedStatementsQuery)).WillReturnRows(rows)
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLPreparedStatements(context.Background(), db, ch); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	labels := prometheus.Labels{"hostgroup": "10", "schemaname": "shop", "username": "app"}
+	counterExpected := []metricResult{
+		{"proxysql_prepared_statements_count", labels, 12, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_ref_count_client", labels, 30, dto.MetricType_GAUGE},
+		{"proxysql_prepared_statements_ref_count_server", labels, 8, dto.MetricType_GAUGE},
+	}
+
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestScrapeMySQLCommandCounterFilter(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	// columns set differs from the one of the current ProxySQL version
+	columns := []string{"Command", "Total_Time_us", "Total_cnt", "cnt_1ms", "cnt_10ms", "cnt_1s", "cnt_INFs", "cnt_unknown"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("COMMIT", "5000", "10", "4", "3", "2", "1", "0").
+		AddRow("SELECT", "100", "1", "1", "0", "0", "0", "0").
+		AddRow("SHOW", "100", "1", "1", "0", "0", "0", "0")
+	mock.ExpectQuery(sanitizeQuery(mysqlCommandCounterQuery)).WillReturnRows(rows)
+
+	filter, err := newCommandCounterFilter("all", "show", "^(COMMIT|SHOW)$")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeMySQLCommandCounterMetrics(context.Background(), db, ch, filter); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	var got []*dto.Metric
+	for m := range ch {
+		var pb dto.Metric
+		if err := m.Write(&pb); err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, &pb)
+	}
+
+	if assert.Len(t, got, 1) {
+		assert.Equal(t, "COMMIT", got[0].GetLabel()[0].GetValue())
+		assert.Equal(t, uint64(10), got[0].GetHistogram().GetSampleCount())
+		var upperBounds []float64
+		var counts []uint64
+		for _, b := range got[0].GetHistogram().GetBucket() {
+			upperBounds = append(upperBounds, b.GetUpperBound())
+			counts = append(counts, b.GetCumulativeCount())
+		}
+		assert.Equal(t, []float64{1, 10, 1000, math.Inf(1)}, upperBounds)
+		assert.Equal(t, []uint64{4, 7, 9, 10}, counts)
+	}
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestCommandCounterFilter(t *testing.T) {
+	f, err := newCommandCounterFilter("SELECT,INSERT", "", "")
+	assert.NoError(t, err)
+	assert.True(t, f.selected("SELECT"))
+	assert.False(t, f.selected("COMMIT"))
+
+	f, err = newCommandCounterFilter("", "select", "")
+	assert.NoError(t, err)
+	assert.True(t, f.selected("COMMIT"))
+	assert.False(t, f.selected("SELECT"))
+
+	_, err = newCommandCounterFilter("", "", "(")
+	assert.Error(t, err)
+}
+
+func TestGlobalVariablesFilter(t *testing.T) {
+	var f *globalVariablesFilter
+	assert.True(t, f.selected("mysql-max_connections"))
+	assert.False(t, f.selected("mysql-poll_timeout"))
+
+	f, err := newGlobalVariablesFilter("mysql-threads", "^mysql-monitor_.*_interval$")
+	assert.NoError(t, err)
+	assert.True(t, f.selected("MYSQL-THREADS"))
+	assert.True(t, f.selected("mysql-monitor_ping_interval"))
+	assert.False(t, f.selected("mysql-max_connections"))
+
+	// password-like variables are never selected
+	f, err = newGlobalVariablesFilter("all", "")
+	assert.NoError(t, err)
+	assert.True(t, f.selected("mysql-poll_timeout"))
+	assert.False(t, f.selected("mysql-monitor_password"))
+	assert.False(t, f.selected("admin-admin_credentials"))
+
+	_, err = newGlobalVariablesFilter("", "(")
+	assert.Error(t, err)
+}
+
+func TestScrapeGlobalVariables(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	columns := []string{"variable_name", "variable_value"}
+	rows := sqlmock.NewRows(columns).
+		AddRow("mysql-max_connections", "2048").
+		AddRow("mysql-monitor_enabled", "true").
+		AddRow("mysql-query_digests", "false").
+		AddRow("mysql-monitor_username", "monitor").
+		AddRow("mysql-monitor_password", "12345").
+		AddRow("mysql-poll_timeout", "2000")
+	mock.ExpectQuery(sanitizeQuery(globalVariablesQuery)).WillReturnRows(rows)
+
+	filter, err := newGlobalVariablesFilter(strings.Join(defaultGlobalVariables, ","), "^mysql-monitor_")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		if err = scrapeGlobalVariables(context.Background(), db, ch, filter); err != nil {
+			t.Errorf("error calling function on test: %s", err)
+		}
+		close(ch)
+	}()
+
+	// non-numeric, password and not selected variables are not collected
+	counterExpected := []metricResult{
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-max_connections"}, 2048, dto.MetricType_GAUGE},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-monitor_enabled"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_global_variable", prometheus.Labels{"name": "mysql-query_digests"}, 0, dto.MetricType_GAUGE},
+	}
+
+	convey.Convey("Metrics comparison", t, convey.FailureContinues, func(cv convey.C) {
+		for _, expect := range counterExpected {
+			got := *readMetric(<-ch)
+			cv.So(got, convey.ShouldResemble, expect)
+		}
+	})
+	if m, ok := <-ch; ok {
+		t.Errorf("unexpected metric: %s", m.Desc())
+	}
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExporterReconnect(t *testing.T) {
+	exporter := NewExporter("admin:admin@tcp(127.0.0.1:1)/?timeout=1s", ExporterOptions{
+		Collectors:       allCollectors(),
+		CollectorOptions: map[string]optionValues{"stats_mysql_users": {"runtime": "true"}},
+	})
+	defer exporter.Close()
+
+	for i := 0; i < 3; i++ {
+		db, err := exporter.db(context.Background())
+		assert.Nil(t, db)
+		assert.Error(t, err)
+	}
+
+	// the first pool is not a reconnect
+	assert.Equal(t, 2.0, readMetric(exporter.reconnectsTotal).value)
+}
+
+func TestExporterDescribe(t *testing.T) {
+	exporter := NewExporter("admin:admin@tcp(127.0.0.1:1)/?timeout=1s", ExporterOptions{Collectors: map[string]bool{"mysql_status": true}})
+	defer exporter.Close()
+
+	// pedantic registry checks descriptors for consistency
+	require.NoError(t, prometheus.NewPedanticRegistry().Register(exporter))
+
+	ch := make(chan *prometheus.Desc)
+	go func() {
+		exporter.Describe(ch)
+		close(ch)
+	}()
+	names := make(map[string]bool)
+	for d := range ch {
+		names[getName(d)] = true
+	}
+	assert.True(t, names["proxysql_mysql_status_client_connections_connected"])
+	assert.True(t, names["proxysql_connection_pool_latency_us"])
+	assert.True(t, names["proxysql_monitor_ping_error"])
+	assert.True(t, names["proxysql_up"])
+
+	// describing does not connect to ProxySQL
+	assert.Nil(t, exporter.dbPool)
+}
+
+func TestOpenDBPasswordFile(t *testing.T) {
+	db, err := openDB("admin@tcp(127.0.0.1:1)/?timeout=1s", "/nonexistent/password")
+	require.NoError(t, err)
+	defer db.Close()
+
+	err = db.PingContext(context.Background())
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "/nonexistent/password")
+}
+
+func TestExporterRunScrapers(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.MatchExpectationsInOrder(false)
+	mock.ExpectQuery(mySQLGlobalQuery).WillReturnRows(sqlmock.NewRows([]string{"Variable_Name", "Variable_Value"}).
+		AddRow("Active_Transactions", "3"))
+	mock.ExpectQuery(sanitizeQuery(memoryMetricsQuery)).WillReturnError(errors.New("error"))
+
+	exporter := NewExporter("", ExporterOptions{Collectors: map[string]bool{"mysql_status": true, "stats_memory_metrics": true}})
+	scrapers := []Collector{
+		&collector{name: "mysql_status", scrape: scrapeMySQLGlobal},
+		&collector{name: "stats_memory_metrics", scrape: scrapeMemoryMetrics},
+	}
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		err = exporter.runScrapers(context.Background(), db, ch, scrapers)
+		close(ch)
+	}()
+
+	var metrics []metricResult
+	for m := range ch {
+		got := *readMetric(m)
+		if got.name == "proxysql_exporter_collector_duration_seconds" {
+			got.value = 0
+		}
+		metrics = append(metrics, got)
+	}
+	assert.Error(t, err)
+
+	assert.ElementsMatch(t, []metricResult{
+		{"proxysql_mysql_status_active_transactions", prometheus.Labels{}, 3, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_duration_seconds", prometheus.Labels{"collector": "collect.mysql_status"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_success", prometheus.Labels{"collector": "collect.mysql_status"}, 1, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_timeout", prometheus.Labels{"collector": "collect.mysql_status"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_duration_seconds", prometheus.Labels{"collector": "collect.stats_memory_metrics"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_success", prometheus.Labels{"collector": "collect.stats_memory_metrics"}, 0, dto.MetricType_GAUGE},
+		{"proxysql_exporter_collector_timeout", prometheus.Labels{"collector": "collect.stats_memory_metrics"}, 0, dto.MetricType_GAUGE},
+	}, metrics)
+	assert.Equal(t, 1.0, readMetric(exporter.scrapeErrorsTotal.WithLabelValues("collect.stats_memory_metrics")).value)
+
+	// Ensure all SQL queries were executed
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Errorf("there were unfulfilled expectations: %s", err)
+	}
+}
+
+func TestExporterRunScrapersTimeout(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	if err != nil {
+		t.Fatalf("error opening a stub database connection: %s", err)
+	}
+	defer db.Close()
+
+	mock.ExpectQuery(mySQLGlobalQuery).WillDelayFor(time.Second).WillReturnRows(sqlmock.NewRows([]string{"Variable_Name", "Variable_Value"}).
+		AddRow("Active_Transactions", "3"))
+
+	exporter := NewExporter("", ExporterOptions{Collectors: map[string]bool{"mysql_status": true}})
+	scrapers := []Collector{
+		&collector{name: "mysql_status", scrape: scrapeMySQLGlobal},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	ch := make(chan prometheus.Metric)
+	go func() {
+		err = exporter.runScrapers(ctx, db, ch, scrapers)
+		close(ch)
+	}()
+
+	metrics := make(map[string]float64)
+	for m := range ch {
+		got := readMetric(m)
+		metrics[got.name] = got.value
+	}
+	assert.Error(t, err)
+	assert.Equal(t, 0.0, metrics["proxysql_exporter_collector_success"])
+	assert.Equal(t, 1.0, metrics["proxysql_exporter_collector_timeout"])
+	assert.NotContains(t, metrics, "proxysql_mysql_status_active_transactions")
+}
+
+func TestExporter(t *testing.T) {
+	if testing.Short() {
+		t.Skip("-short is passed, skipping integration test")
+	}
+
+	// wait up to 30 seconds for ProxySQL to become available
+	exporter := NewExporter("admin:admin@tcp(127.0.0.1:16032)/", ExporterOptions{
+		Collectors:       allCollectors(),
+		CollectorOptions: map[string]optionValues{"stats_mysql_users": {"runtime": "true"}},
+	})
 	for i := 0; i < 30; i++ {
-		db, err := exporter.db()
+		db, err := exporter.db(context.Background())
 		if err != nil {
 			time.Sleep(time.Second)
 			continue
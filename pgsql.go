@@ -0,0 +1,109 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// ProxySQL 3.x proxies PostgreSQL with stats_pgsql_* and runtime_pgsql_* tables mirroring MySQL ones.
+// Their metrics use the same names as MySQL metrics with pgsql_ subsystem prefix.
+
+const pgSQLGlobalQuery = "SELECT Variable_Name, Variable_Value FROM stats_pgsql_global"
+
+// key - variable name in lowercase.
+var pgSQLGlobalMetrics = map[string]*metric{
+	"active_transactions": {"active_transactions", prometheus.GaugeValue,
+		"Current number of active transactions."},
+	"client_connections_aborted": {"client_connections_aborted", prometheus.CounterValue,
+		"Total number of frontend connections aborted due to invalid credential or max_connections reached."},
+	"client_connections_connected": {"client_connections_connected", prometheus.GaugeValue,
+		"Current number of frontend connections."},
+	"client_connections_created": {"client_connections_created", prometheus.CounterValue,
+		"Total number of frontend connections created so far."},
+	"client_connections_non_idle": {"client_connections_non_idle", prometheus.GaugeValue,
+		"Current number of client connections that are not idle."},
+	"proxysql_uptime": {"proxysql_uptime", prometheus.CounterValue,
+		"Uptime in seconds."},
+	"questions": {"questions", prometheus.CounterValue,
+		"Total number of queries sent from frontends."},
+	"slow_queries": {"slow_queries", prometheus.CounterValue,
+		"Total number of queries that ran for longer than the threshold in milliseconds defined in global variable pgsql-long_query_time."},
+}
+
+var pgSQLGlobalDescs = newMetricDescs("pgsql_status", nil, pgSQLGlobalMetrics, "Undocumented stats_pgsql_global metric.")
+
+// scrapePgSQLGlobal collects metrics from `stats_pgsql_global`.
+func scrapePgSQLGlobal(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeGlobal(ctx, db, ch, pgSQLGlobalQuery, pgSQLGlobalDescs)
+}
+
+const pgSQLConnectionPoolQuery = "SELECT hostgroup, srv_host, srv_port, * FROM stats_pgsql_connection_pool"
+
+var pgSQLConnectionPoolDescs = newMetricDescs("pgsql_connection_pool", []string{"hostgroup", "endpoint"}, mySQLconnectionPoolMetrics,
+	"Undocumented stats_pgsql_connection_pool metric.")
+
+// scrapePgSQLConnectionPool collects metrics from `stats_pgsql_connection_pool`.
+func scrapePgSQLConnectionPool(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeConnectionPool(ctx, db, ch, pgSQLConnectionPoolQuery, pgSQLConnectionPoolDescs)
+}
+
+const pgSQLProcessListQuery = "SELECT user, database, cli_host, hostgroup, COUNT(*) AS count FROM stats_pgsql_processlist GROUP BY user, database, cli_host, hostgroup"
+
+var pgSQLProcessListDescs = newMetricDescs("pgsql_processlist", []string{"user", "database", "client_host", "hostgroup"},
+	map[string]*metric{
+		"detailed_connection_count": {"detailed_client_connection_count", prometheus.GaugeValue,
+			"Number of client connections per user, database, host and hostgroup."},
+	}, "")
+
+// scrapePgSQLProcessList collects client connection counts from `stats_pgsql_processlist`.
+func scrapePgSQLProcessList(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeDetailedConnectionList(ctx, db, ch, pgSQLProcessListQuery, pgSQLProcessListDescs)
+}
+
+// Digests are aggregated over client_address like in mySQLQueryDigestQuery.
+const pgSQLQueryDigestQuery = `
+    SELECT
+        hostgroup, database, username, digest,
+        SUM(count_star) AS count_star, SUM(sum_time) AS sum_time, MIN(min_time) AS min_time, MAX(max_time) AS max_time,
+        SUM(sum_rows_affected) AS sum_rows_affected, SUM(sum_rows_sent) AS sum_rows_sent
+    FROM
+        stats_pgsql_query_digest
+    %s
+    GROUP BY hostgroup, database, username, digest
+    ORDER BY %s DESC
+    LIMIT %d
+`
+
+var pgSQLQueryDigestDescs = newMetricDescs("pgsql_query_digest", []string{"hostgroup", "database", "username", "digest"},
+	mySQLQueryDigestMetrics, "")
+
+// scrapePgSQLQueryDigest collects top-N digests from `stats_pgsql_query_digest`.
+func scrapePgSQLQueryDigest(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric, limit int, orderBy string) error {
+	return scrapeQueryDigest(ctx, db, ch, "stats_pgsql_query_digest", pgSQLQueryDigestQuery, pgSQLQueryDigestDescs, limit, orderBy, "")
+}
+
+const pgSQLRuntimeServersQuery = "SELECT hostgroup_id, hostname, port, * FROM runtime_pgsql_servers"
+
+var pgSQLRuntimeServersDescs = newMetricDescs("pgsql_runtime_servers", []string{"hostgroup", "endpoint"}, mySQLruntimeServersMetrics,
+	"Undocumented runtime_pgsql_servers metric.")
+
+// scrapePgSQLRuntimeServers collects metrics from `runtime_pgsql_servers`.
+func scrapePgSQLRuntimeServers(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+	return scrapeRuntimeServers(ctx, db, ch, pgSQLRuntimeServersQuery, pgSQLRuntimeServersDescs)
+}
@@ -0,0 +1,90 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/DATA-DOG/go-sqlmock.v1"
+)
+
+func TestScrapePgSQL(t *testing.T) {
+	db, mock, err := sqlmock.New()
+	require.NoError(t, err)
+	defer db.Close()
+
+	mock.ExpectQuery(pgSQLGlobalQuery).WillReturnRows(sqlmock.NewRows([]string{"Variable_Name", "Variable_Value"}).
+		AddRow("Client_Connections_connected", "5").
+		AddRow("Backend_query_time_nsec", "100"))
+	mock.ExpectQuery(sanitizeQuery(pgSQLConnectionPoolQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"hostgroup", "srv_host", "srv_port", "hostgroup", "srv_host", "srv_port", "status", "ConnUsed", "Latency_us"}).
+			AddRow("1", "pg-1", "5432", "1", "pg-1", "5432", "ONLINE", "2", "150"))
+	mock.ExpectQuery(sanitizeQuery(pgSQLProcessListQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"user", "database", "cli_host", "hostgroup", "count"}).
+			AddRow("app", "orders", "10.0.0.1", "1", "3"))
+	mock.ExpectQuery(sanitizeQuery(fmt.Sprintf(pgSQLQueryDigestQuery, "", "sum_time", 10))).WillReturnRows(
+		sqlmock.NewRows([]string{"hostgroup", "database", "username", "digest", "count_star", "sum_time"}).
+			AddRow("1", "orders", "app", "0x1234", "7", "700"))
+	mock.ExpectQuery(sanitizeQuery(pgSQLRuntimeServersQuery)).WillReturnRows(
+		sqlmock.NewRows([]string{"hostgroup_id", "hostname", "port", "hostgroup_id", "hostname", "port", "status", "weight", "comment"}).
+			AddRow("1", "pg-1", "5432", "1", "pg-1", "5432", "SHUNNED", "100", "primary"))
+
+	var metrics []metricResult
+	for _, scrape := range []scrapeFunc{
+		scrapePgSQLGlobal,
+		scrapePgSQLConnectionPool,
+		scrapePgSQLProcessList,
+		func(ctx context.Context, db *sql.DB, ch chan<- prometheus.Metric) error {
+			return scrapePgSQLQueryDigest(ctx, db, ch, 10, "sum_time")
+		},
+		scrapePgSQLRuntimeServers,
+	} {
+		ch := make(chan prometheus.Metric)
+		go func() {
+			err = scrape(context.Background(), db, ch)
+			close(ch)
+		}()
+		for m := range ch {
+			metrics = append(metrics, *readMetric(m))
+		}
+		require.NoError(t, err)
+	}
+
+	endpoint := prometheus.Labels{"hostgroup": "1", "endpoint": "pg-1:5432"}
+	digest := prometheus.Labels{"hostgroup": "1", "database": "orders", "username": "app", "digest": "0x1234"}
+	assert.Equal(t, []metricResult{
+		{"proxysql_pgsql_status_client_connections_connected", prometheus.Labels{}, 5, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_status_backend_query_time_nsec", prometheus.Labels{}, 100, dto.MetricType_UNTYPED},
+		{"proxysql_pgsql_connection_pool_status", endpoint, 1, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_connection_pool_conn_used", endpoint, 2, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_connection_pool_latency_us", endpoint, 150, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_processlist_detailed_client_connection_count", prometheus.Labels{
+			"user": "app", "database": "orders", "client_host": "10.0.0.1", "hostgroup": "1",
+		}, 3, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_query_digest_count_star", digest, 7, dto.MetricType_COUNTER},
+		{"proxysql_pgsql_query_digest_sum_time_us", digest, 700, dto.MetricType_COUNTER},
+		{"proxysql_pgsql_runtime_servers_status", endpoint, 2, dto.MetricType_GAUGE},
+		{"proxysql_pgsql_runtime_servers_weight", endpoint, 100, dto.MetricType_GAUGE},
+	}, metrics)
+
+	require.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,141 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// defaultAuthModule is used by probe requests without auth_module parameter.
+const defaultAuthModule = "default"
+
+// exporterCache keeps exporters (and their connection pools) for probe targets between scrapes.
+// Exporters not used for idleTimeout are closed by expire.
+type exporterCache struct {
+	idleTimeout time.Duration
+
+	mtx       sync.Mutex
+	opts      ExporterOptions
+	exporters map[string]*cachedExporter
+}
+
+type cachedExporter struct {
+	exporter *Exporter
+	lastUsed time.Time
+}
+
+func newExporterCache(opts ExporterOptions, idleTimeout time.Duration) *exporterCache {
+	return &exporterCache{
+		idleTimeout: idleTimeout,
+		opts:        probeOptions(opts),
+		exporters:   make(map[string]*cachedExporter),
+	}
+}
+
+// get returns exporter for the given DSN, creating it if needed.
+func (c *exporterCache) get(dsn string, now time.Time) *Exporter {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	e := c.exporters[dsn]
+	if e == nil {
+		e = &cachedExporter{exporter: NewExporter(dsn, c.opts)}
+		c.exporters[dsn] = e
+	}
+	e.lastUsed = now
+	return e.exporter
+}
+
+// update sets options of cached and new exporters.
+func (c *exporterCache) update(opts ExporterOptions) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	c.opts = probeOptions(opts)
+	for dsn, e := range c.exporters {
+		e.exporter.Update(dsn, c.opts)
+	}
+}
+
+// probeOptions returns options of probe target exporters. PasswordFile of /metrics target is cleared:
+// probe targets use passwords of auth modules.
+func probeOptions(opts ExporterOptions) ExporterOptions {
+	opts.PasswordFile = ""
+	return opts
+}
+
+// expire closes and removes exporters which were not used for idleTimeout.
+func (c *exporterCache) expire(now time.Time) {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for dsn, e := range c.exporters {
+		if now.Sub(e.lastUsed) < c.idleTimeout {
+			continue
+		}
+		if err := e.exporter.Close(); err != nil {
+			logger.Warn("Failed to close connection pool of idle probe target", "error", err)
+		}
+		delete(c.exporters, dsn)
+	}
+}
+
+// run periodically expires idle exporters. It never returns.
+func (c *exporterCache) run() {
+	for now := range time.Tick(c.idleTimeout / 2) {
+		c.expire(now)
+	}
+}
+
+// newProbeHandler returns multi-target handler which collects metrics from ProxySQL given by target parameter (host:port)
+// with credentials from auth module given by auth_module parameter. Auth modules are taken from the current configuration.
+func newProbeHandler(config func() *Config, exporters *exporterCache, timeoutOffset float64) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := r.URL.Query()
+		target := params.Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is missing", http.StatusBadRequest)
+			return
+		}
+		if _, _, err := net.SplitHostPort(target); err != nil {
+			http.Error(w, fmt.Sprintf("invalid target %q: %s", target, err), http.StatusBadRequest)
+			return
+		}
+
+		name := params.Get("auth_module")
+		if name == "" {
+			name = defaultAuthModule
+		}
+		module, ok := config().AuthModules[name]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown auth module %q", name), http.StatusBadRequest)
+			return
+		}
+
+		ctx, cancel := scrapeContext(r, timeoutOffset)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporters.get(module.dsn(target), time.Now()).WithContext(ctx))
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+	})
+}
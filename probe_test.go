@@ -0,0 +1,98 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExporterCache(t *testing.T) {
+	cache := newExporterCache(ExporterOptions{Collectors: map[string]bool{"mysql_status": true}}, time.Minute)
+	now := time.Now()
+
+	e1 := cache.get("admin:admin@tcp(proxysql-1:6032)/", now)
+	e2 := cache.get("admin:admin@tcp(proxysql-2:6032)/", now)
+	assert.NotSame(t, e1, e2)
+
+	now = now.Add(50 * time.Second)
+	assert.Same(t, e1, cache.get("admin:admin@tcp(proxysql-1:6032)/", now))
+
+	now = now.Add(20 * time.Second)
+	cache.expire(now)
+	assert.Len(t, cache.exporters, 1)
+	assert.Same(t, e1, cache.get("admin:admin@tcp(proxysql-1:6032)/", now))
+	assert.NotSame(t, e2, cache.get("admin:admin@tcp(proxysql-2:6032)/", now))
+}
+
+func TestExporterCachePasswordFile(t *testing.T) {
+	opts := ExporterOptions{PasswordFile: "/nonexistent/password"}
+	cache := newExporterCache(opts, time.Minute)
+	module := AuthModule{Username: "admin", Password: "secret", Params: map[string]string{"timeout": "1s"}}
+
+	// the password of auth module is used, not the one from /metrics target password file
+	check := func() {
+		e := cache.get(module.dsn("127.0.0.1:1"), time.Now())
+		dsn, opts := e.settings()
+		assert.Equal(t, "admin:secret@tcp(127.0.0.1:1)/?timeout=1s", dsn)
+		assert.Empty(t, opts.PasswordFile)
+
+		_, err := e.db(context.Background())
+		require.Error(t, err)
+		assert.NotContains(t, err.Error(), "/nonexistent/password")
+	}
+	check()
+	cache.update(opts)
+	check()
+}
+
+func TestProbeHandler(t *testing.T) {
+	cfg := &Config{AuthModules: map[string]AuthModule{
+		"default": {Username: "admin", Password: "admin", Params: map[string]string{"timeout": "1s"}},
+	}}
+	cache := newExporterCache(ExporterOptions{Collectors: map[string]bool{"mysql_status": true}}, time.Minute)
+	srv := httptest.NewServer(newProbeHandler(func() *Config { return cfg }, cache, 0.25))
+	defer srv.Close()
+
+	get := func(query string) (int, string) {
+		resp, err := http.Get(srv.URL + "?" + query)
+		require.NoError(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return resp.StatusCode, string(body)
+	}
+
+	code, _ := get("")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, _ = get("target=127.0.0.1")
+	assert.Equal(t, http.StatusBadRequest, code)
+	code, body := get("target=127.0.0.1:1&auth_module=prod")
+	assert.Equal(t, http.StatusBadRequest, code)
+	assert.Contains(t, body, `unknown auth module "prod"`)
+
+	code, body = get("target=127.0.0.1:1")
+	assert.Equal(t, http.StatusOK, code)
+	assert.Contains(t, body, "proxysql_up 0")
+	assert.NotContains(t, body, "go_goroutines")
+	assert.Contains(t, cache.exporters, "admin:admin@tcp(127.0.0.1:1)/?timeout=1s")
+}
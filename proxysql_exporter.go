@@ -15,10 +15,22 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"errors"
 	"flag"
 	"fmt"
+	"html/template"
 	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"slices"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
 
 	_ "github.com/go-sql-driver/mysql"
 	"github.com/percona/exporter_shared"
@@ -26,6 +38,7 @@ import (
 	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/version"
+	"gopkg.in/yaml.v2"
 )
 
 const (
@@ -37,14 +50,26 @@ var (
 	versionF       = flag.Bool("version", false, "Print version information and exit.")
 	listenAddressF = flag.String("web.listen-address", ":42004", "Address to listen on for web interface and telemetry.")
 	telemetryPathF = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+	probePathF     = flag.String("web.probe-path", "/probe", "Path under which to expose metrics of multi-target probes.")
+	authFileF      = flag.String("web.auth-file", "", "Path to YAML file with server_user, server_password keys for HTTP Basic authentication (overrides HTTP_AUTH environment variable).")
+	sslCertFileF   = flag.String("web.ssl-cert-file", "", "Path to SSL certificate file.")
+	sslKeyFileF    = flag.String("web.ssl-key-file", "", "Path to SSL key file.")
+	configFileF    = flag.String("config.file", "", "Path to YAML configuration file.")
+	probeIdleF     = flag.Duration("probe.idle-timeout", 5*time.Minute, "Close connection pool to a probe target which was not scraped for that duration.")
+	timeoutOffsetF = flag.Float64("scrape.timeout-offset", 0.25, "Offset in seconds to subtract from the timeout sent by Prometheus in X-Prometheus-Scrape-Timeout-Seconds header.")
 
-	mysqlStatusF                 = flag.Bool("collect.mysql_status", true, "Collect from stats_mysql_global (SHOW MYSQL STATUS).")
-	mysqlConnectionPoolF         = flag.Bool("collect.mysql_connection_pool", true, "Collect from stats_mysql_connection_pool.")
-	mysqlConnectionListF         = flag.Bool("collect.mysql_connection_list", true, "Collect connection list from stats_mysql_processlist.")
-	mysqlDetailedConnectionListF = flag.Bool("collect.detailed.stats_mysql_processlist", false, "Collect detailed connection list from stats_mysql_processlist.")
-	mysqlCommandCounter          = flag.Bool("collect.stats_command_counter", false, "Collect histograms over command latency")
-	mysqlRuntimeServers          = flag.Bool("collect.runtime_mysql_servers", false, "Collect from runtime_mysql_servers.")
-	memoryMetricsF               = flag.Bool("collect.stats_memory_metrics", false, "Collect memory metrics from stats_memory_metrics.")
+	proxysqlAddressF      = flag.String("proxysql.address", "", "Address (host:port) of ProxySQL admin interface; overrides DATA_SOURCE_NAME.")
+	proxysqlUserF         = flag.String("proxysql.user", "", "User of ProxySQL admin interface; overrides DATA_SOURCE_NAME.")
+	proxysqlPasswordFileF = flag.String("proxysql.password-file", "", "Path to file with password of ProxySQL admin interface user; re-read for every new connection.")
+
+	proxysqlTLSCAFileF     = flag.String("proxysql.tls.ca-file", "", "Path to CA certificate file used to verify ProxySQL admin interface server certificate; enables TLS.")
+	proxysqlTLSCertFileF   = flag.String("proxysql.tls.cert-file", "", "Path to client certificate file for ProxySQL admin interface; enables TLS.")
+	proxysqlTLSKeyFileF    = flag.String("proxysql.tls.key-file", "", "Path to client key file for ProxySQL admin interface; enables TLS.")
+	proxysqlTLSServerNameF = flag.String("proxysql.tls.server-name", "", "Server name used to verify ProxySQL admin interface server certificate (default is host); enables TLS.")
+	proxysqlTLSInsecureF   = flag.Bool("proxysql.tls.insecure-skip-verify", false, "Skip verification of ProxySQL admin interface server certificate; enables TLS.")
+
+	collectorsF       = collectorFlags(flag.CommandLine, collectors)
+	collectorOptionsF = collectorOptionFlags(flag.CommandLine, collectors)
 
 	logLevel = flag.String("log.level", "error", "Only log messages with the given severity or above. Valid levels: [debug, info, warn, error]")
 	logger   = slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{})) //nolint:gochecknoglobals,exhaustruct
@@ -54,6 +79,7 @@ func main() {
 	flag.Usage = func() {
 		fmt.Fprintf(os.Stderr, "%s %s exports various ProxySQL metrics in Prometheus format.\n", os.Args[0], version.Version)
 		fmt.Fprintf(os.Stderr, "It uses DATA_SOURCE_NAME environment variable with following format: https://github.com/go-sql-driver/mysql#dsn-data-source-name\n")
+		fmt.Fprintf(os.Stderr, "or --proxysql.address, --proxysql.user and --proxysql.password-file flags.\n")
 		fmt.Fprintf(os.Stderr, "Default value is %q.\n\n", defaultDataSource)
 		fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n\n", os.Args[0])
 		fmt.Fprintf(os.Stderr, "Flags:\n")
@@ -78,16 +104,324 @@ func main() {
 
 	logger = promslog.New(promlogConfig)
 
-	dsn := os.Getenv("DATA_SOURCE_NAME")
+	if *probeIdleF <= 0 {
+		logger.Error(fmt.Sprintf("error: not a valid probe idle timeout: %s, try --help", *probeIdleF))
+		os.Exit(1)
+	}
+
+	cmdline := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { cmdline[f.Name] = true })
+
+	exporter := NewExporter(defaultDataSource, ExporterOptions{})
+	exporters := newExporterCache(ExporterOptions{}, *probeIdleF)
+	observer := &tlsObserver{}
+	prometheus.MustRegister(observer)
+	reloader := newReloader(*configFileF, cmdline, exporter, exporters, observer)
+	if err := reloader.reload(); err != nil {
+		logger.Error(fmt.Sprintf("error: %s, try --help", err))
+		os.Exit(1)
+	}
+	prometheus.MustRegister(reloader)
+
+	dsn, _ := exporter.settings()
+	logger.Info(fmt.Sprintf("Starting %s %s for %s", program, version.Version, redactDSN(dsn)))
+
+	go exporters.run()
+
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			if err := reloader.reload(); err != nil {
+				logger.Error("Failed to reload configuration", "error", err)
+				continue
+			}
+			logger.Info("Configuration reloaded")
+		}
+	}()
+
+	username, password, err := basicAuthCredentials()
+	if err != nil {
+		logger.Error(fmt.Sprintf("error: %s", err))
+		os.Exit(1)
+	}
+
+	handlers := map[string]http.Handler{
+		*telemetryPathF: newHandler(exporter, *timeoutOffsetF),
+		*probePathF:     newProbeHandler(reloader.config, exporters, *timeoutOffsetF),
+	}
+	if username != "" {
+		handlers["/-/reload"] = newReloadHandler(reloader)
+	} else {
+		logger.Info("HTTP basic authentication is not configured, /-/reload endpoint is disabled; use SIGHUP to reload configuration")
+	}
+	runServer(*listenAddressF, handlers, username, password)
+}
+
+// exporterOptions returns exporter options from collect.* flags.
+// It returns error if options of any collector are invalid.
+func exporterOptions() (ExporterOptions, error) {
+	enabled := make(map[string]bool, len(collectorsF))
+	for name, f := range collectorsF {
+		enabled[name] = *f
+	}
+
+	options := make(map[string]optionValues, len(collectorOptionsF))
+	for name, flags := range collectorOptionsF {
+		options[name] = make(optionValues, len(flags))
+		for option, f := range flags {
+			options[name][option] = f.String()
+		}
+	}
+
+	opts := ExporterOptions{
+		Collectors:       enabled,
+		CollectorOptions: options,
+	}
+	for _, c := range collectors {
+		if _, err := configureCollector(c, opts); err != nil {
+			return ExporterOptions{}, err
+		}
+	}
+	return opts, nil
+}
+
+// dataSource returns DSN and password file for ProxySQL admin interface.
+// proxysql.* flags have the highest priority, then DATA_SOURCE_NAME environment variable,
+// then configuration file, then the default DSN.
+// If TLS is configured, it is registered in MySQL driver with observer recording handshakes.
+func dataSource(p ProxySQLConfig, observer *tlsObserver) (string, string, error) {
+	fromFlags := *proxysqlAddressF != "" || *proxysqlUserF != "" || *proxysqlPasswordFileF != ""
+	if fromFlags {
+		p.DSN = ""
+		if *proxysqlAddressF != "" {
+			p.Address, p.Host, p.Port = *proxysqlAddressF, "", 0
+		}
+		if *proxysqlUserF != "" {
+			p.User = *proxysqlUserF
+		}
+		if *proxysqlPasswordFileF != "" {
+			p.PasswordFile = *proxysqlPasswordFileF
+		}
+		if p.Address == "" && p.Host == "" {
+			return "", "", errors.New("--proxysql.address is required")
+		}
+	}
+
+	var dsn, passwordFile string
+	if env := os.Getenv("DATA_SOURCE_NAME"); env != "" && !fromFlags {
+		dsn = env
+	} else {
+		var err error
+		if dsn, err = p.dsn(); err != nil {
+			return "", "", err
+		}
+		if p.DSN == "" {
+			passwordFile = p.PasswordFile
+		}
+	}
 	if dsn == "" {
 		dsn = defaultDataSource
 	}
 
-	logger.Info(fmt.Sprintf("Starting %s %s for %s", program, version.Version, dsn))
+	t := p.TLS
+	if *proxysqlTLSCAFileF != "" {
+		t.CAFile = *proxysqlTLSCAFileF
+	}
+	if *proxysqlTLSCertFileF != "" {
+		t.CertFile = *proxysqlTLSCertFileF
+	}
+	if *proxysqlTLSKeyFileF != "" {
+		t.KeyFile = *proxysqlTLSKeyFileF
+	}
+	if *proxysqlTLSServerNameF != "" {
+		t.ServerName = *proxysqlTLSServerNameF
+	}
+	if *proxysqlTLSInsecureF {
+		t.InsecureSkipVerify = true
+	}
+	if t != (TLSConfig{}) {
+		var err error
+		if dsn, err = registerTLS(dsn, t, observer); err != nil {
+			return "", "", err
+		}
+	}
+	return dsn, passwordFile, nil
+}
 
-	exporter := NewExporter(dsn, *mysqlStatusF, *mysqlConnectionPoolF, *mysqlConnectionListF, *mysqlDetailedConnectionListF,
-		*mysqlRuntimeServers, *memoryMetricsF, *mysqlCommandCounter)
-	prometheus.MustRegister(exporter)
+// splitList splits comma-separated list, dropping empty elements.
+func splitList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			res = append(res, v)
+		}
+	}
+	return res
+}
+
+// scrapeContext returns scrape request context.
+// If Prometheus sends X-Prometheus-Scrape-Timeout-Seconds header, the context deadline is set
+// to that timeout minus timeoutOffset, so that partial metrics are returned before Prometheus gives up.
+func scrapeContext(r *http.Request, timeoutOffset float64) (context.Context, context.CancelFunc) {
+	v := r.Header.Get("X-Prometheus-Scrape-Timeout-Seconds")
+	if v == "" {
+		return context.WithCancel(r.Context())
+	}
+
+	timeout, err := strconv.ParseFloat(v, 64)
+	switch {
+	case err != nil:
+		logger.Error("Failed to parse scrape timeout", "value", v, "error", err)
+	case timeout <= timeoutOffset:
+		logger.Warn("Scrape timeout is not greater than timeout offset, ignoring it", "timeout", timeout, "offset", timeoutOffset)
+	default:
+		return context.WithTimeout(r.Context(), time.Duration((timeout-timeoutOffset)*float64(time.Second)))
+	}
+	return context.WithCancel(r.Context())
+}
+
+// newHandler returns metrics handler which collects metrics from ProxySQL with the scrape request context
+// together with exporter's own metrics from default registry.
+func newHandler(exporter *Exporter, timeoutOffset float64) http.Handler {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, cancel := scrapeContext(r, timeoutOffset)
+		defer cancel()
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter.WithContext(ctx))
+		gatherers := prometheus.Gatherers{prometheus.DefaultGatherer, registry}
+		promhttp.HandlerFor(gatherers, promhttp.HandlerOpts{ErrorHandling: promhttp.ContinueOnError}).ServeHTTP(w, r)
+	})
+	return promhttp.InstrumentMetricHandler(prometheus.DefaultRegisterer, handler)
+}
 
-	exporter_shared.RunServer("ProxySQL", *listenAddressF, *telemetryPathF, promhttp.Handler())
+var landingPage = template.Must(template.New("home").Parse(strings.TrimSpace(`
+<html>
+<head>
+	<title>ProxySQL exporter</title>
+</head>
+<body>
+	<h1>ProxySQL exporter</h1>
+	{{ range . }}<p><a href="{{ . }}">{{ . }}</a></p>
+	{{ end }}
+</body>
+</html>
+`)))
+
+// runServer runs HTTP(S) server on given address with given handlers (keyed by path) and landing page.
+// It replaces exporter_shared.RunServer which serves a single path and reads kingpin flags not parsed by this exporter,
+// but keeps its behavior: see serverHandler. Function never returns.
+func runServer(addr string, handlers map[string]http.Handler, username, password string) {
+	if (*sslCertFileF == "") != (*sslKeyFileF == "") {
+		logger.Error("One of the flags --web.ssl-cert-file or --web.ssl-key-file is missing to enable HTTPS.")
+		os.Exit(1)
+	}
+	ssl := *sslCertFileF != ""
+
+	handler, err := serverHandler(handlers, username, password, ssl)
+	if err != nil {
+		logger.Error(fmt.Sprintf("error: %s", err))
+		os.Exit(1)
+	}
+	if username != "" {
+		logger.Info("HTTP Basic authentication is enabled.")
+	}
+
+	srv := &http.Server{
+		Addr:    addr,
+		Handler: handler,
+	}
+	if ssl {
+		srv.TLSConfig = exporter_shared.TLSConfig()
+		logger.Info(fmt.Sprintf("Starting HTTPS server for https://%s ...", addr))
+		err = srv.ListenAndServeTLS(*sslCertFileF, *sslKeyFileF)
+	} else {
+		logger.Info(fmt.Sprintf("Starting HTTP server for http://%s ...", addr))
+		err = srv.ListenAndServe()
+	}
+	logger.Error(fmt.Sprintf("error: %s", err))
+	os.Exit(1)
+}
+
+// serverHandler returns handler serving given handlers (keyed by path) and landing page like exporter_shared.RunServer:
+// handlers are protected by HTTP basic authentication if username is not empty, the landing page is not;
+// Strict-Transport-Security header is sent if ssl is true.
+func serverHandler(handlers map[string]http.Handler, username, password string, ssl bool) (http.Handler, error) {
+	// lifecycle endpoints like /-/reload are not listed on landing page
+	var paths []string
+	for path := range handlers {
+		if !strings.HasPrefix(path, "/-/") {
+			paths = append(paths, path)
+		}
+	}
+	slices.Sort(paths)
+
+	var buf bytes.Buffer
+	if err := landingPage.Execute(&buf, paths); err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	for path, handler := range handlers {
+		mux.Handle(path, basicAuth(handler, username, password))
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(buf.Bytes()) //nolint:errcheck
+	})
+	if !ssl {
+		return mux, nil
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Strict-Transport-Security", "max-age=63072000; includeSubDomains")
+		mux.ServeHTTP(w, r)
+	}), nil
+}
+
+// basicAuthCredentials returns HTTP basic authentication credentials from --web.auth-file
+// or HTTP_AUTH environment variable. Like in exporter_shared, authentication is enabled only if both username
+// and password are set; empty username is returned otherwise.
+func basicAuthCredentials() (string, string, error) {
+	if *authFileF != "" {
+		b, err := os.ReadFile(*authFileF)
+		if err != nil {
+			return "", "", err
+		}
+		var auth struct {
+			Username string `yaml:"server_user,omitempty"`
+			Password string `yaml:"server_password,omitempty"`
+		}
+		if err = yaml.Unmarshal(b, &auth); err != nil {
+			return "", "", fmt.Errorf("failed to parse %s: %w", *authFileF, err)
+		}
+		if auth.Username == "" || auth.Password == "" {
+			return "", "", nil
+		}
+		return auth.Username, auth.Password, nil
+	}
+
+	if v := os.Getenv("HTTP_AUTH"); v != "" {
+		username, password, ok := strings.Cut(v, ":")
+		if !ok || username == "" || password == "" {
+			return "", "", errors.New("HTTP_AUTH should be formatted as user:password")
+		}
+		return username, password, nil
+	}
+	return "", "", nil
+}
+
+// basicAuth returns handler protected by HTTP basic authentication if username is not empty.
+func basicAuth(handler http.Handler, username, password string) http.Handler {
+	if username == "" {
+		return handler
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		u, p, ok := r.BasicAuth()
+		if !ok || subtle.ConstantTimeCompare([]byte(u), []byte(username)) != 1 || subtle.ConstantTimeCompare([]byte(p), []byte(password)) != 1 {
+			w.Header().Set("WWW-Authenticate", `Basic realm="metrics"`)
+			http.Error(w, "Invalid username or password", http.StatusUnauthorized)
+			return
+		}
+		handler.ServeHTTP(w, r)
+	})
 }
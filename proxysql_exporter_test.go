@@ -0,0 +1,145 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	exporter := NewExporter("admin:admin@tcp(127.0.0.1:1)/?timeout=1s", ExporterOptions{Collectors: map[string]bool{"mysql_status": true}})
+	defer exporter.Close()
+
+	srv := httptest.NewServer(newHandler(exporter, 0.25))
+	defer srv.Close()
+
+	req, err := http.NewRequest(http.MethodGet, srv.URL, nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Prometheus-Scrape-Timeout-Seconds", "10")
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "proxysql_up 0")
+	assert.Contains(t, string(body), "proxysql_exporter_scrapes_total 1")
+	assert.Contains(t, string(body), "go_goroutines")
+}
+
+func TestDataSource(t *testing.T) {
+	t.Cleanup(func() { *proxysqlAddressF, *proxysqlUserF, *proxysqlPasswordFileF = "", "", "" })
+	passwordFile := filepath.Join(t.TempDir(), "password")
+	require.NoError(t, os.WriteFile(passwordFile, []byte("secret\n"), 0o600))
+
+	t.Setenv("DATA_SOURCE_NAME", "")
+	dsn, file, err := dataSource(ProxySQLConfig{}, &tlsObserver{})
+	require.NoError(t, err)
+	assert.Equal(t, defaultDataSource, dsn)
+	assert.Equal(t, "", file)
+
+	dsn, file, err = dataSource(ProxySQLConfig{Address: "proxysql-1:6032", User: "stats", PasswordFile: passwordFile}, &tlsObserver{})
+	require.NoError(t, err)
+	assert.Equal(t, "stats@tcp(proxysql-1:6032)/", dsn)
+	assert.Equal(t, passwordFile, file)
+
+	t.Setenv("DATA_SOURCE_NAME", "admin:admin@tcp(proxysql-2:6032)/")
+	dsn, file, err = dataSource(ProxySQLConfig{Address: "proxysql-1:6032", User: "stats", PasswordFile: passwordFile}, &tlsObserver{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin:admin@tcp(proxysql-2:6032)/", dsn)
+	assert.Equal(t, "", file)
+
+	*proxysqlUserF = "admin"
+	_, _, err = dataSource(ProxySQLConfig{}, &tlsObserver{})
+	assert.EqualError(t, err, "--proxysql.address is required")
+
+	*proxysqlAddressF, *proxysqlPasswordFileF = "proxysql-3:6032", passwordFile
+	dsn, file, err = dataSource(ProxySQLConfig{DSN: "stats:stats@tcp(proxysql-1:6032)/"}, &tlsObserver{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin@tcp(proxysql-3:6032)/", dsn)
+	assert.Equal(t, passwordFile, file)
+}
+
+func TestServerHandler(t *testing.T) {
+	metrics := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { io.WriteString(w, "metrics") }) //nolint:errcheck
+	handler, err := serverHandler(map[string]http.Handler{"/metrics": metrics}, "user", "pass", true)
+	require.NoError(t, err)
+
+	get := func(path, username, password string) *http.Response {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if username != "" {
+			req.SetBasicAuth(username, password)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Result()
+	}
+
+	// landing page is not protected
+	resp := get("/", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=63072000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+
+	assert.Equal(t, http.StatusUnauthorized, get("/metrics", "", "").StatusCode)
+	assert.Equal(t, http.StatusUnauthorized, get("/metrics", "user", "wrong").StatusCode)
+	resp = get("/metrics", "user", "pass")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "max-age=63072000; includeSubDomains", resp.Header.Get("Strict-Transport-Security"))
+
+	handler, err = serverHandler(map[string]http.Handler{"/metrics": metrics}, "", "", false)
+	require.NoError(t, err)
+	resp = get("/metrics", "", "")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Empty(t, resp.Header.Get("Strict-Transport-Security"))
+}
+
+func TestBasicAuthCredentials(t *testing.T) {
+	t.Setenv("HTTP_AUTH", "user:pa:ss")
+	username, password, err := basicAuthCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pa:ss", password)
+
+	for _, v := range []string{"user", "user:", ":pass"} {
+		t.Setenv("HTTP_AUTH", v)
+		_, _, err = basicAuthCredentials()
+		assert.EqualError(t, err, "HTTP_AUTH should be formatted as user:password", v)
+	}
+
+	// both username and password are required in auth file
+	path := filepath.Join(t.TempDir(), "auth.yml")
+	require.NoError(t, os.WriteFile(path, []byte("server_user: user\n"), 0o600))
+	*authFileF = path
+	t.Cleanup(func() { *authFileF = "" })
+	username, password, err = basicAuthCredentials()
+	require.NoError(t, err)
+	assert.Empty(t, username)
+	assert.Empty(t, password)
+
+	require.NoError(t, os.WriteFile(path, []byte("server_user: user\nserver_password: pass\n"), 0o600))
+	username, password, err = basicAuthCredentials()
+	require.NoError(t, err)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+}
@@ -0,0 +1,153 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// reloader loads configuration file and applies it to exporters.
+// It implements prometheus.Collector interface for reload metrics.
+type reloader struct {
+	configFile string
+	// cmdline contains names of flags set on the command line; they override configuration file.
+	cmdline   map[string]bool
+	exporter  *Exporter
+	exporters *exporterCache
+	observer  *tlsObserver
+
+	mtx sync.Mutex
+	cfg *Config
+
+	lastReloadSuccessful       prometheus.Gauge
+	lastReloadSuccessTimestamp prometheus.Gauge
+}
+
+func newReloader(configFile string, cmdline map[string]bool, exporter *Exporter, exporters *exporterCache, observer *tlsObserver) *reloader {
+	return &reloader{
+		configFile: configFile,
+		cmdline:    cmdline,
+		exporter:   exporter,
+		exporters:  exporters,
+		observer:   observer,
+		cfg:        &Config{},
+
+		lastReloadSuccessful: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_successful",
+			Help:      "Whether the last configuration reload attempt was successful (1 for success, 0 for error).",
+		}),
+		lastReloadSuccessTimestamp: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "exporter",
+			Name:      "config_last_reload_success_timestamp_seconds",
+			Help:      "Timestamp of the last successful configuration reload.",
+		}),
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (r *reloader) Describe(ch chan<- *prometheus.Desc) {
+	r.lastReloadSuccessful.Describe(ch)
+	r.lastReloadSuccessTimestamp.Describe(ch)
+}
+
+// Collect implements prometheus.Collector.
+func (r *reloader) Collect(ch chan<- prometheus.Metric) {
+	r.lastReloadSuccessful.Collect(ch)
+	r.lastReloadSuccessTimestamp.Collect(ch)
+}
+
+// config returns current configuration.
+func (r *reloader) config() *Config {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return r.cfg
+}
+
+// reload reads configuration file and validates it together with flags.
+// Only valid configuration is applied to exporters; otherwise the current one is kept.
+func (r *reloader) reload() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	dsn, opts, cfg, err := r.load()
+	if err != nil {
+		// restore flags changed by the new configuration
+		if e := r.cfg.applyToFlags(flag.CommandLine, r.cmdline); e != nil {
+			logger.Error("Failed to restore flags from the current configuration", "error", e)
+		}
+		r.lastReloadSuccessful.Set(0)
+		return err
+	}
+
+	r.cfg = cfg
+	r.exporter.Update(dsn, opts)
+	r.exporters.update(opts)
+	r.lastReloadSuccessful.Set(1)
+	r.lastReloadSuccessTimestamp.SetToCurrentTime()
+	return nil
+}
+
+// load reads configuration file and returns DSN and exporter options from it and flags.
+func (r *reloader) load() (string, ExporterOptions, *Config, error) {
+	cfg := &Config{}
+	if r.configFile != "" {
+		var err error
+		if cfg, err = loadConfig(r.configFile); err != nil {
+			return "", ExporterOptions{}, nil, err
+		}
+	}
+	if err := cfg.applyToFlags(flag.CommandLine, r.cmdline); err != nil {
+		return "", ExporterOptions{}, nil, fmt.Errorf("%s: %w", r.configFile, err)
+	}
+
+	opts, err := exporterOptions()
+	if err != nil {
+		return "", ExporterOptions{}, nil, err
+	}
+
+	dsn, passwordFile, err := dataSource(cfg.ProxySQL, r.observer)
+	if err != nil {
+		return "", ExporterOptions{}, nil, err
+	}
+	opts.PasswordFile = passwordFile
+	return dsn, opts, cfg, nil
+}
+
+// newReloadHandler returns handler which reloads configuration on POST request.
+func newReloadHandler(r *reloader) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Only POST requests allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := r.reload(); err != nil {
+			logger.Error("Failed to reload configuration", "error", err)
+			http.Error(w, fmt.Sprintf("failed to reload configuration: %s", err), http.StatusInternalServerError)
+			return
+		}
+		logger.Info("Configuration reloaded")
+	})
+}
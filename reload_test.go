@@ -0,0 +1,122 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"flag"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReloader(t *testing.T) {
+	t.Setenv("DATA_SOURCE_NAME", "")
+	t.Cleanup(func() { require.NoError(t, (&Config{}).applyToFlags(flag.CommandLine, nil)) })
+
+	path := filepath.Join(t.TempDir(), "config.yml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+proxysql:
+  dsn: stats:secret1@tcp(proxysql-1:6032)/
+collectors:
+  mysql_status: false
+  stats_mysql_query_digest: true
+collector_options:
+  stats_mysql_users:
+    runtime: true
+  stats_mysql_client_host_cache:
+    limit: 10
+  stats_mysql_query_digest:
+    schemanames: [app, sbtest]
+  runtime_global_variables:
+    names: [mysql-max_connections, mysql-threads]
+    regex: ^mysql-monitor_
+`), 0o600))
+
+	exporter := NewExporter(defaultDataSource, ExporterOptions{})
+	exporters := newExporterCache(ExporterOptions{}, time.Minute)
+	probeExporter := exporters.get("admin:admin@tcp(proxysql-2:6032)/", time.Now())
+	r := newReloader(path, map[string]bool{}, exporter, exporters, &tlsObserver{})
+
+	require.NoError(t, r.reload())
+	dsn, opts := exporter.settings()
+	assert.Equal(t, "stats:secret1@tcp(proxysql-1:6032)/", dsn)
+	assert.False(t, opts.Collectors["mysql_status"])
+	assert.True(t, opts.Collectors["stats_mysql_query_digest"])
+	assert.Equal(t, "true", opts.CollectorOptions["stats_mysql_users"]["runtime"])
+	assert.Equal(t, "10", opts.CollectorOptions["stats_mysql_client_host_cache"]["limit"])
+	assert.Equal(t, "app,sbtest", opts.CollectorOptions["stats_mysql_query_digest"]["schemanames"])
+	assert.Equal(t, optionValues{"names": "mysql-max_connections,mysql-threads", "regex": "^mysql-monitor_"},
+		opts.CollectorOptions["runtime_global_variables"])
+	_, opts = probeExporter.settings()
+	assert.True(t, opts.Collectors["stats_mysql_query_digest"])
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.lastReloadSuccessful))
+	assert.NotZero(t, testutil.ToFloat64(r.lastReloadSuccessTimestamp))
+
+	// invalid configuration is not applied
+	require.NoError(t, os.WriteFile(path, []byte(`
+proxysql:
+  dsn: stats:secret2@tcp(proxysql-1:6032)/
+collectors:
+  mysql_status: true
+collector_options:
+  stats_mysql_query_digest:
+    order_by: digest_text
+`), 0o600))
+	assert.Error(t, r.reload())
+	require.NoError(t, os.WriteFile(path, []byte(`
+proxysql:
+  dsn: stats:secret2@tcp(proxysql-1:6032)/
+collectors:
+  mysql_status: true
+collector_options:
+  runtime_global_variables:
+    regex: "("
+`), 0o600))
+	assert.Error(t, r.reload())
+	dsn, opts = exporter.settings()
+	assert.Equal(t, "stats:secret1@tcp(proxysql-1:6032)/", dsn)
+	assert.False(t, opts.Collectors["mysql_status"])
+	assert.False(t, *collectorsF["mysql_status"])
+	assert.Equal(t, 0.0, testutil.ToFloat64(r.lastReloadSuccessful))
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+proxysql:
+  dsn: stats:secret2@tcp(proxysql-1:6032)/
+`), 0o600))
+	srv := httptest.NewServer(newReloadHandler(r))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	resp, err = http.Post(srv.URL, "", nil)
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	dsn, opts = exporter.settings()
+	assert.Equal(t, "stats:secret2@tcp(proxysql-1:6032)/", dsn)
+	assert.True(t, opts.Collectors["mysql_status"])
+	assert.False(t, opts.Collectors["stats_mysql_query_digest"])
+	assert.Equal(t, 1.0, testutil.ToFloat64(r.lastReloadSuccessful))
+}
@@ -0,0 +1,170 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// proxysqlTLSConfigName is the name of TLS configuration registered in MySQL driver for ProxySQL admin interface.
+const proxysqlTLSConfigName = "proxysql"
+
+var (
+	tlsHandshakeSuccessDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "tls_handshake_success"),
+		"Whether the last TLS handshake with ProxySQL admin interface, including server certificate verification, was successful (1 for success, 0 for error).",
+		nil, nil,
+	)
+	tlsCertificateExpiryDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "exporter", "tls_certificate_expiry_timestamp_seconds"),
+		"Expiry time of ProxySQL admin interface server certificate seen during the last TLS handshake.",
+		nil, nil,
+	)
+)
+
+// tlsObserver records the outcome of TLS handshakes with ProxySQL admin interface.
+// It implements prometheus.Collector interface.
+type tlsObserver struct {
+	mtx      sync.Mutex
+	observed bool
+	success  bool
+	notAfter time.Time
+}
+
+// observe records handshake outcome and server certificate.
+func (o *tlsObserver) observe(cs tls.ConnectionState, err error) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	o.observed = true
+	o.success = err == nil
+	if len(cs.PeerCertificates) > 0 {
+		o.notAfter = cs.PeerCertificates[0].NotAfter
+	}
+}
+
+// Describe implements prometheus.Collector.
+func (o *tlsObserver) Describe(ch chan<- *prometheus.Desc) {
+	ch <- tlsHandshakeSuccessDesc
+	ch <- tlsCertificateExpiryDesc
+}
+
+// Collect implements prometheus.Collector.
+func (o *tlsObserver) Collect(ch chan<- prometheus.Metric) {
+	o.mtx.Lock()
+	defer o.mtx.Unlock()
+
+	if !o.observed {
+		return
+	}
+	var success float64
+	if o.success {
+		success = 1
+	}
+	ch <- prometheus.MustNewConstMetric(tlsHandshakeSuccessDesc, prometheus.GaugeValue, success)
+	if !o.notAfter.IsZero() {
+		ch <- prometheus.MustNewConstMetric(tlsCertificateExpiryDesc, prometheus.GaugeValue, float64(o.notAfter.Unix()))
+	}
+}
+
+// tlsConfig returns TLS configuration with CA and client certificate loaded from files.
+// Server certificate is verified against serverName (unless ServerName is set) by VerifyConnection callback,
+// so that the outcome is recorded by observer even if verification fails.
+func (t TLSConfig) tlsConfig(serverName string, observer *tlsObserver) (*tls.Config, error) {
+	if t.ServerName != "" {
+		serverName = t.ServerName
+	}
+	cfg := &tls.Config{ //nolint:gosec
+		ServerName: serverName,
+		// verification is done by VerifyConnection below
+		InsecureSkipVerify: true,
+	}
+	if t.CAFile != "" {
+		b, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.RootCAs = x509.NewCertPool()
+		if !cfg.RootCAs.AppendCertsFromPEM(b) {
+			return nil, fmt.Errorf("no certificates found in %s", t.CAFile)
+		}
+	}
+	if t.CertFile != "" || t.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	cfg.VerifyConnection = func(cs tls.ConnectionState) error {
+		var err error
+		if !t.InsecureSkipVerify {
+			err = verifyServerCertificate(cs, cfg.RootCAs, serverName)
+		}
+		observer.observe(cs, err)
+		return err
+	}
+	return cfg, nil
+}
+
+// verifyServerCertificate verifies server certificate chain and name like crypto/tls does.
+func verifyServerCertificate(cs tls.ConnectionState, roots *x509.CertPool, serverName string) error {
+	if len(cs.PeerCertificates) == 0 {
+		return errors.New("tls: server did not provide a certificate")
+	}
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		DNSName:       serverName,
+		Intermediates: x509.NewCertPool(),
+	}
+	for _, cert := range cs.PeerCertificates[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	_, err := cs.PeerCertificates[0].Verify(opts)
+	return err
+}
+
+// registerTLS registers TLS configuration in MySQL driver and returns DSN which uses it.
+func registerTLS(dsn string, t TLSConfig, observer *tlsObserver) (string, error) {
+	cfg, err := mysql.ParseDSN(dsn)
+	if err != nil {
+		return "", err
+	}
+	host, _, err := net.SplitHostPort(cfg.Addr)
+	if err != nil {
+		host = cfg.Addr
+	}
+
+	tlsConfig, err := t.tlsConfig(host, observer)
+	if err != nil {
+		return "", err
+	}
+	if err = mysql.RegisterTLSConfig(proxysqlTLSConfigName, tlsConfig); err != nil {
+		return "", err
+	}
+	cfg.TLSConfig = proxysqlTLSConfigName
+	return cfg.FormatDSN(), nil
+}
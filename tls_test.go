@@ -0,0 +1,80 @@
+// Copyright 2016-2017 Percona LLC
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//   http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTLSConfig(t *testing.T) {
+	srv := httptest.NewTLSServer(http.NotFoundHandler())
+	defer srv.Close()
+	addr := srv.Listener.Addr().String()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	require.NoError(t, os.WriteFile(caFile, ca, 0o600))
+
+	handshake := func(cfg TLSConfig) (*tlsObserver, error) {
+		observer := &tlsObserver{}
+		tlsConfig, err := cfg.tlsConfig("127.0.0.1", observer)
+		require.NoError(t, err)
+		conn, err := tls.Dial("tcp", addr, tlsConfig)
+		if err == nil {
+			conn.Close()
+		}
+		return observer, err
+	}
+
+	observer, err := handshake(TLSConfig{CAFile: caFile})
+	require.NoError(t, err)
+	assert.Equal(t, 2, testutil.CollectAndCount(observer))
+	assert.True(t, observer.success)
+	assert.Equal(t, srv.Certificate().NotAfter, observer.notAfter)
+
+	observer, err = handshake(TLSConfig{})
+	assert.Error(t, err)
+	assert.False(t, observer.success)
+	assert.Equal(t, srv.Certificate().NotAfter, observer.notAfter)
+
+	observer, err = handshake(TLSConfig{CAFile: caFile, ServerName: "proxysql.example.org"})
+	assert.Error(t, err)
+	assert.False(t, observer.success)
+
+	observer, err = handshake(TLSConfig{InsecureSkipVerify: true})
+	require.NoError(t, err)
+	assert.True(t, observer.success)
+
+	assert.Equal(t, 0, testutil.CollectAndCount(&tlsObserver{}))
+}
+
+func TestRegisterTLS(t *testing.T) {
+	dsn, err := registerTLS("admin:admin@tcp(127.0.0.1:6032)/?timeout=5s", TLSConfig{InsecureSkipVerify: true}, &tlsObserver{})
+	require.NoError(t, err)
+	assert.Equal(t, "admin:admin@tcp(127.0.0.1:6032)/?timeout=5s&tls=proxysql", dsn)
+
+	_, err = registerTLS("admin:admin@tcp(127.0.0.1:6032)/", TLSConfig{CAFile: "/nonexistent/ca.pem"}, &tlsObserver{})
+	assert.Error(t, err)
+}